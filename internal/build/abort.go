@@ -0,0 +1,117 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+var (
+	abortLock            sync.Mutex
+	abortCtx, abortBuild = context.WithCancel(context.Background())
+	signalOnce           sync.Once
+)
+
+// AbortContext returns the context that is cancelled when the build is aborted,
+// e.g. by an interrupt caught via InstallSignalHandler.
+func AbortContext() context.Context {
+	abortLock.Lock()
+	defer abortLock.Unlock()
+	return abortCtx
+}
+
+// InstallSignalHandler cancels the AbortContext on SIGINT or SIGTERM, causing
+// running commands to be killed and further ones to be refused. It is safe to
+// call multiple times.
+func InstallSignalHandler() {
+	signalOnce.Do(func() {
+		sigc := make(chan os.Signal, 1)
+		signal.Notify(sigc, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			sig := <-sigc
+			log.Printf("Received %v, aborting build", sig)
+
+			abortLock.Lock()
+			abortBuild()
+			abortLock.Unlock()
+		}()
+	})
+}
+
+// RunAbortable executes the given command with its output connected to the
+// console, killing it if the build is aborted. Once aborted, commands are not
+// started anymore and the context error is returned instead.
+func RunAbortable(cmd *exec.Cmd) error {
+	fmt.Println(">>>", strings.Join(cmd.Args, " "))
+	if *DryRunFlag {
+		return nil
+	}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return runAbortable(cmd)
+}
+
+// runAbortable starts the given, fully configured command and waits for it to
+// finish, killing it if the build is aborted meanwhile.
+func runAbortable(cmd *exec.Cmd) error {
+	wait, err := startAbortable(cmd)
+	if err != nil {
+		return err
+	}
+	return wait()
+}
+
+// startAbortable starts the given, fully configured command, killing it if the
+// build is aborted before it finishes. The returned function must be used in
+// place of cmd.Wait, it reports the context error if the command was aborted.
+// This allows callers to consume the output pipes of the command meanwhile.
+func startAbortable(cmd *exec.Cmd) (func() error, error) {
+	ctx := AbortContext()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	done := make(chan struct{})
+	killed := make(chan bool, 1)
+	go func() {
+		select {
+		case <-ctx.Done():
+			cmd.Process.Kill()
+			killed <- true
+		case <-done:
+			killed <- false
+		}
+	}()
+	return func() error {
+		err := cmd.Wait()
+		close(done)
+		if <-killed {
+			return ctx.Err()
+		}
+		return err
+	}, nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+// resetAbort replaces an aborted build context with a fresh one.
+func resetAbort() {
+	abortLock.Lock()
+	abortCtx, abortBuild = context.WithCancel(context.Background())
+	abortLock.Unlock()
+}
+
+// Tests that aborting the build kills running commands and refuses new ones.
+func TestRunAbortable(t *testing.T) {
+	skipNoShell(t)
+	defer resetAbort()
+
+	errc := make(chan error, 1)
+	go func() { errc <- RunAbortable(exec.Command("sleep", "30")) }()
+
+	time.Sleep(100 * time.Millisecond)
+	abortLock.Lock()
+	abortBuild()
+	abortLock.Unlock()
+
+	select {
+	case err := <-errc:
+		if err != context.Canceled {
+			t.Errorf("in-flight command error mismatch: have %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("in-flight command not killed on abort")
+	}
+	start := time.Now()
+	if err := RunAbortable(exec.Command("sleep", "30")); err != context.Canceled {
+		t.Errorf("post-abort command error mismatch: have %v, want %v", err, context.Canceled)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("post-abort command took %v to return", elapsed)
+	}
+}
+
+// abortAfter aborts the build after the given delay.
+func abortAfter(delay time.Duration) {
+	time.AfterFunc(delay, func() {
+		abortLock.Lock()
+		abortBuild()
+		abortLock.Unlock()
+	})
+}
+
+// Tests that the run helpers kill their commands when the build is aborted.
+func TestRunHelpersAbortable(t *testing.T) {
+	skipNoShell(t)
+
+	helpers := map[string]func(cmd *exec.Cmd) error{
+		"RunTailBuffer": func(cmd *exec.Cmd) error {
+			_, err := RunTailBuffer(cmd, 10)
+			return err
+		},
+		"RunJSON": func(cmd *exec.Cmd) error {
+			var v interface{}
+			return RunJSON(cmd, &v)
+		},
+		"RunJSONStream": func(cmd *exec.Cmd) error {
+			return RunJSONStream(cmd, func(json.RawMessage) error { return nil })
+		},
+		"runColored": func(cmd *exec.Cmd) error {
+			return runColored(cmd, ioutil.Discard, ioutil.Discard, true)
+		},
+		"runFiltered": func(cmd *exec.Cmd) error {
+			return runFiltered(cmd, ioutil.Discard, ioutil.Discard, func(line string) (string, bool) { return line, true })
+		},
+	}
+	for name, run := range helpers {
+		abortAfter(100 * time.Millisecond)
+
+		start := time.Now()
+		err := run(exec.Command("sleep", "30"))
+		resetAbort()
+
+		if err != context.Canceled {
+			t.Errorf("%s: aborted command error mismatch: have %v, want %v", name, err, context.Canceled)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("%s: command not killed on abort, took %v", name, elapsed)
+		}
+	}
+}
@@ -19,12 +19,16 @@ package build
 import (
 	"archive/tar"
 	"archive/zip"
+	"bytes"
 	"compress/gzip"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 )
 
 type Archive interface {
@@ -73,8 +77,58 @@ func AddFile(a Archive, file string) error {
 	return nil
 }
 
+// ArchiveOptions are the optional settings of WriteArchiveOptions.
+type ArchiveOptions struct {
+	// StripPrefix, if set, is removed from the path of every file to form its
+	// entry name, preserving the rest of the directory structure. All files
+	// must be located below it. If unset, files are added by their base name.
+	StripPrefix string
+
+	// Reproducible makes the archive depend only on the content, names and
+	// permissions of the files: entries are sorted by name and their timestamps
+	// and ownership are normalized.
+	Reproducible bool
+}
+
+// reproducibleModTime is the timestamp of all entries in reproducible archives,
+// chosen as the earliest time representable in zip files.
+var reproducibleModTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
 // WriteArchive creates an archive containing the given files.
-func WriteArchive(name string, files []string) (err error) {
+func WriteArchive(name string, files []string) error {
+	return WriteArchiveOptions(name, files, ArchiveOptions{})
+}
+
+// WriteArchiveOptions creates an archive containing the given files, using the
+// provided options.
+func WriteArchiveOptions(name string, files []string, opts ArchiveOptions) error {
+	return writeArchive(name, files, opts, NewArchive)
+}
+
+// WriteTarGz creates a gzipped tarball containing the given files, regardless of
+// the extension of name.
+func WriteTarGz(name string, files []string, opts ArchiveOptions) error {
+	return writeArchive(name, files, opts, func(file *os.File) (Archive, string) {
+		base := strings.TrimSuffix(strings.TrimSuffix(file.Name(), ".tgz"), ".tar.gz")
+		if opts.Reproducible {
+			return newTarballArchive(file, ReproducibleGzipWriter(file)), base
+		}
+		return NewTarballArchive(file), base
+	})
+}
+
+// writeArchive creates the archive file name with the given constructor and adds
+// all files to it. The partially written archive is removed on failure.
+func writeArchive(name string, files []string, opts ArchiveOptions, newArchive func(*os.File) (Archive, string)) (err error) {
+	entries := make([]string, len(files))
+	for i, file := range files {
+		entries[i] = filepath.Base(file)
+		if opts.StripPrefix != "" {
+			if entries[i], err = stripPathPrefix(file, opts.StripPrefix); err != nil {
+				return err
+			}
+		}
+	}
 	archfd, err := os.Create(name)
 	if err != nil {
 		return err
@@ -87,7 +141,7 @@ func WriteArchive(name string, files []string) (err error) {
 			os.Remove(name)
 		}
 	}()
-	archive, basename := NewArchive(archfd)
+	archive, basename := newArchive(archfd)
 	if archive == nil {
 		return fmt.Errorf("unknown archive extension")
 	}
@@ -95,15 +149,130 @@ func WriteArchive(name string, files []string) (err error) {
 	if err := archive.Directory(basename); err != nil {
 		return err
 	}
-	for _, file := range files {
-		fmt.Println("   +", filepath.Base(file))
-		if err := AddFile(archive, file); err != nil {
+	order := make([]int, len(files))
+	for i := range order {
+		order[i] = i
+	}
+	if opts.Reproducible {
+		sort.Stable(entryOrder{order, entries})
+	}
+	for _, i := range order {
+		fmt.Println("   +", entries[i])
+		if err := addFileAs(archive, files[i], entries[i], opts.Reproducible); err != nil {
 			return err
 		}
 	}
 	return archive.Close()
 }
 
+// entryOrder sorts the indexes of archive files by their entry names.
+type entryOrder struct {
+	order   []int
+	entries []string
+}
+
+func (o entryOrder) Len() int           { return len(o.order) }
+func (o entryOrder) Less(i, j int) bool { return o.entries[o.order[i]] < o.entries[o.order[j]] }
+func (o entryOrder) Swap(i, j int)      { o.order[i], o.order[j] = o.order[j], o.order[i] }
+
+// stripPathPrefix returns the path of a file relative to the prefix directory
+// in slash separated form, suitable for an archive entry name. Files outside of
+// the prefix are rejected, since their names would leak build paths.
+func stripPathPrefix(path, prefix string) (string, error) {
+	abspath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	absprefix, err := filepath.Abs(prefix)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(absprefix, abspath)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is not within archive prefix %s", path, prefix)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// addFileAs appends an existing file to an archive under the given entry name,
+// which may contain slash separated directories. If normalize is set, the
+// modification time and ownership of the file are not recorded.
+func addFileAs(a Archive, file, entry string, normalize bool) error {
+	fd, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+	fi, err := fd.Stat()
+	if err != nil {
+		return err
+	}
+	w, err := a.Header(namedFileInfo{fi, entry, normalize})
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, fd); err != nil {
+		return err
+	}
+	return nil
+}
+
+// namedFileInfo overrides the name of a file, which archive headers are
+// constructed from. Normalized infos additionally hide the modification time
+// and the system specific details (owner, group) of the file.
+type namedFileInfo struct {
+	os.FileInfo
+	name      string
+	normalize bool
+}
+
+func (fi namedFileInfo) Name() string { return fi.name }
+
+func (fi namedFileInfo) ModTime() time.Time {
+	if fi.normalize {
+		return reproducibleModTime
+	}
+	return fi.FileInfo.ModTime()
+}
+
+func (fi namedFileInfo) Sys() interface{} {
+	if fi.normalize {
+		return nil
+	}
+	return fi.FileInfo.Sys()
+}
+
+// AssertArchiveReproducible invokes build twice to create an archive at the same
+// temporary path and returns an error reporting the first differing offset if
+// the two archives aren't byte-identical.
+func AssertArchiveReproducible(build func(out string) error) error {
+	dir, err := ioutil.TempDir("", "build-repro-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, "archive")
+	var blobs [2][]byte
+	for i := range blobs {
+		os.Remove(out)
+		if err := build(out); err != nil {
+			return fmt.Errorf("build %d failed: %v", i+1, err)
+		}
+		if blobs[i], err = ioutil.ReadFile(out); err != nil {
+			return fmt.Errorf("build %d output unreadable: %v", i+1, err)
+		}
+	}
+	if bytes.Equal(blobs[0], blobs[1]) {
+		return nil
+	}
+	offset := 0
+	for offset < len(blobs[0]) && offset < len(blobs[1]) && blobs[0][offset] == blobs[1][offset] {
+		offset++
+	}
+	return fmt.Errorf("archive is not reproducible: outputs differ at offset %d (sizes %d and %d)", offset, len(blobs[0]), len(blobs[1]))
+}
+
 type ZipArchive struct {
 	dir  string
 	zipw *zip.Writer
@@ -148,11 +317,28 @@ type TarballArchive struct {
 }
 
 func NewTarballArchive(w io.WriteCloser) Archive {
-	gzw := gzip.NewWriter(w)
+	return newTarballArchive(w, gzip.NewWriter(w))
+}
+
+// newTarballArchive creates a tarball archive compressed by the given gzip
+// writer, which must be writing into w.
+func newTarballArchive(w io.WriteCloser, gzw *gzip.Writer) Archive {
 	tarw := tar.NewWriter(gzw)
 	return &TarballArchive{"", tarw, gzw, w}
 }
 
+// ReproducibleGzipWriter returns a gzip writer into w whose header doesn't
+// depend on the time or the host: the modification time, name and comment are
+// left unset and the OS is recorded as unknown. This matches the defaults of
+// gzip.NewWriter, but makes them an explicit guarantee callers can rely on
+// instead of an implementation detail. Callers must not set the header fields
+// themselves, e.g. from the metadata of the compressed file.
+func ReproducibleGzipWriter(w io.Writer) *gzip.Writer {
+	gzw := gzip.NewWriter(w)
+	gzw.Header = gzip.Header{OS: 255} // 255 is "unknown" in RFC 1952
+	return gzw
+}
+
 func (a *TarballArchive) Directory(name string) error {
 	a.dir = name + "/"
 	return a.tarw.WriteHeader(&tar.Header{
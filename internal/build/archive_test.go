@@ -0,0 +1,230 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// archiveEntries returns the sorted names of all file entries in a .zip or
+// .tar.gz archive.
+func archiveEntries(t *testing.T, path string) []string {
+	var names []string
+	if filepath.Ext(path) == ".zip" {
+		r, err := zip.OpenReader(path)
+		if err != nil {
+			t.Fatalf("failed to open zip: %v", err)
+		}
+		defer r.Close()
+		for _, f := range r.File {
+			names = append(names, f.Name)
+		}
+	} else {
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatalf("failed to open tarball: %v", err)
+		}
+		defer f.Close()
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatalf("failed to open gzip stream: %v", err)
+		}
+		tr := tar.NewReader(gz)
+		for {
+			head, err := tr.Next()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("failed to read tar entry: %v", err)
+			}
+			if head.Typeflag != tar.TypeDir {
+				names = append(names, head.Name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Tests that the build directory prefix is removed from archive entry names.
+func TestWriteArchiveStripPrefix(t *testing.T) {
+	root := newTestTree(t, map[string]os.FileMode{
+		"build/bin/geth":      0755,
+		"build/docs/COPYING":  0644,
+		"build/docs/man/geth": 0644,
+	})
+	defer os.RemoveAll(root)
+
+	// Archive names are relative to the working directory, as in ci.go
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get working directory: %v", err)
+	}
+	if err := os.Chdir(root); err != nil {
+		t.Fatalf("failed to enter test tree: %v", err)
+	}
+	defer os.Chdir(cwd)
+
+	files := []string{
+		filepath.Join(root, "build", "bin", "geth"),
+		filepath.Join(root, "build", "docs", "COPYING"),
+		filepath.Join(root, "build", "docs", "man", "geth"),
+	}
+	want := []string{
+		"geth-1.6.1/bin/geth",
+		"geth-1.6.1/docs/COPYING",
+		"geth-1.6.1/docs/man/geth",
+	}
+	for _, ext := range []string{".zip", ".tar.gz"} {
+		name := "geth-1.6.1" + ext
+		if err := WriteArchiveOptions(name, files, ArchiveOptions{StripPrefix: filepath.Join(root, "build")}); err != nil {
+			t.Fatalf("%s: failed to write archive: %v", ext, err)
+		}
+		if have := archiveEntries(t, name); !reflect.DeepEqual(have, want) {
+			t.Errorf("%s: entries mismatch: have %v, want %v", ext, have, want)
+		}
+	}
+	// Files outside of the prefix must be rejected, even if their directory name
+	// starts with the prefix
+	sibling := filepath.Join(root, "build2", "geth")
+	if err := os.MkdirAll(filepath.Dir(sibling), 0755); err != nil {
+		t.Fatalf("failed to create sibling dir: %v", err)
+	}
+	if err := ioutil.WriteFile(sibling, []byte("geth"), 0755); err != nil {
+		t.Fatalf("failed to write sibling file: %v", err)
+	}
+	for _, file := range []string{sibling, filepath.Join(root, "README")} {
+		err := WriteArchiveOptions("escape.zip", append(files, file), ArchiveOptions{StripPrefix: filepath.Join(root, "build")})
+		if err == nil || !strings.Contains(err.Error(), "not within archive prefix") {
+			t.Errorf("%s: outside file error mismatch: %v", file, err)
+		}
+		if _, err := os.Stat("escape.zip"); !os.IsNotExist(err) {
+			t.Errorf("%s: archive created despite outside file", file)
+		}
+	}
+	// Without the option, entries should be flattened to their base names
+	name := "flat.zip"
+	if err := WriteArchive(name, files[:2]); err != nil {
+		t.Fatalf("failed to write archive: %v", err)
+	}
+	if have, want := archiveEntries(t, name), []string{"flat/COPYING", "flat/geth"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("flat entries mismatch: have %v, want %v", have, want)
+	}
+}
+
+// Tests that reproducible tarballs are byte-stable even if the timestamps of the
+// input files change, while nondeterministic builds are detected.
+func TestAssertArchiveReproducible(t *testing.T) {
+	root := newTestTree(t, map[string]os.FileMode{
+		"build/bin/geth":     0755,
+		"build/bin/bootnode": 0755,
+		"build/COPYING":      0644,
+	})
+	defer os.RemoveAll(root)
+
+	files := []string{
+		filepath.Join(root, "build", "bin", "geth"),
+		filepath.Join(root, "build", "COPYING"),
+		filepath.Join(root, "build", "bin", "bootnode"),
+	}
+	stamp := time.Now()
+	build := func(out string) error {
+		// Shift the input timestamps between builds to catch leaked mtimes
+		stamp = stamp.Add(time.Hour)
+		for _, file := range files {
+			if err := os.Chtimes(file, stamp, stamp); err != nil {
+				return err
+			}
+		}
+		return WriteTarGz(out, files, ArchiveOptions{StripPrefix: filepath.Join(root, "build"), Reproducible: true})
+	}
+	if err := AssertArchiveReproducible(build); err != nil {
+		t.Errorf("reproducible tarball rejected: %v", err)
+	}
+	nondeterministic := func(out string) error {
+		return ioutil.WriteFile(out, []byte(time.Now().Format(time.RFC3339Nano)), 0644)
+	}
+	err := AssertArchiveReproducible(nondeterministic)
+	if err == nil {
+		t.Fatalf("nondeterministic archive accepted")
+	}
+	if !strings.Contains(err.Error(), "offset") {
+		t.Errorf("error missing differing offset: %v", err)
+	}
+}
+
+// Tests that the same input compresses into identical gzip streams, without a
+// timestamp in the header.
+func TestReproducibleGzipWriter(t *testing.T) {
+	compress := func(gzw *gzip.Writer, buf *bytes.Buffer) []byte {
+		if _, err := gzw.Write([]byte(strings.Repeat("geth ", 1000))); err != nil {
+			t.Fatalf("failed to compress: %v", err)
+		}
+		if err := gzw.Close(); err != nil {
+			t.Fatalf("failed to flush: %v", err)
+		}
+		return buf.Bytes()
+	}
+	reproducible := func() []byte {
+		var buf bytes.Buffer
+		return compress(ReproducibleGzipWriter(&buf), &buf)
+	}
+	// A writer recording the host and the time, as e.g. gzip(1) does
+	stamped := func(mtime time.Time) []byte {
+		var buf bytes.Buffer
+		gzw := gzip.NewWriter(&buf)
+		gzw.Header.ModTime, gzw.Header.OS = mtime, 3 // Unix
+		return compress(gzw, &buf)
+	}
+	first, second := reproducible(), reproducible()
+	if !bytes.Equal(first, second) {
+		t.Fatalf("compressed outputs differ:\n%x\n%x", first, second)
+	}
+	if bytes.Equal(stamped(time.Unix(1490000000, 0)), stamped(time.Unix(1490000001, 0))) {
+		t.Fatalf("stamped outputs don't depend on the modification time")
+	}
+	have, want := first, stamped(time.Unix(1490000000, 0))
+	if bytes.Equal(have, want) {
+		t.Fatalf("reproducible output matches stamped output")
+	}
+	// The outputs may only differ in the header's MTIME (bytes 4-7) and OS (byte 9)
+	for i := range have {
+		if have[i] != want[i] && (i < 4 || i > 9 || i == 8) {
+			t.Errorf("outputs differ beyond the header at offset %d", i)
+			break
+		}
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(first))
+	if err != nil {
+		t.Fatalf("failed to open gzip stream: %v", err)
+	}
+	if !gz.ModTime.IsZero() || gz.OS != 255 || gz.Name != "" || gz.Comment != "" {
+		t.Errorf("header not normalized: mtime %v, os %d, name %q, comment %q", gz.ModTime, gz.OS, gz.Name, gz.Comment)
+	}
+}
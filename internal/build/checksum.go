@@ -0,0 +1,205 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// fileSHA256 returns the hex encoded SHA-256 digest of the given file.
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// VerifyChecksums checks every file listed in a sha256sum style manifest (lines
+// of "<hex>  <filename>", or "<hex> *<filename>" for binary mode) against its
+// actual SHA-256 digest. Filenames are resolved relative to baseDir. The returned
+// error lists all missing and mismatching files, as well as malformed lines.
+func VerifyChecksums(manifestPath, baseDir string) error {
+	manifest, err := os.Open(manifestPath)
+	if err != nil {
+		return err
+	}
+	defer manifest.Close()
+
+	var (
+		failures []string
+		scanner  = bufio.NewScanner(manifest)
+		lineno   = 0
+	)
+	for scanner.Scan() {
+		lineno++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if trimmed := strings.TrimSpace(line); trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		want, name, ok := parseChecksumLine(line)
+		if !ok {
+			failures = append(failures, fmt.Sprintf("line %d: malformed checksum line", lineno))
+			continue
+		}
+
+		have, err := fileSHA256(filepath.Join(baseDir, filepath.FromSlash(name)))
+		switch {
+		case os.IsNotExist(err):
+			failures = append(failures, name+": missing")
+		case err != nil:
+			failures = append(failures, fmt.Sprintf("%s: %v", name, err))
+		case have != want:
+			failures = append(failures, fmt.Sprintf("%s: checksum mismatch (have %s, want %s)", name, have, want))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("checksum verification failed:\n  %s", strings.Join(failures, "\n  "))
+	}
+	return nil
+}
+
+// parseChecksumLine splits a sha256sum style line into the lowercase hex digest
+// and the file name. The name is everything after the digest's separator, kept
+// verbatim (spaces included) apart from the '*' binary mode marker.
+func parseChecksumLine(line string) (string, string, bool) {
+	sep := strings.IndexByte(line, ' ')
+	if sep != sha256.Size*2 {
+		return "", "", false
+	}
+	digest := strings.ToLower(line[:sep])
+	if _, err := hex.DecodeString(digest); err != nil {
+		return "", "", false
+	}
+	name := line[sep+1:]
+	if strings.HasPrefix(name, " ") || strings.HasPrefix(name, "*") {
+		name = name[1:]
+	}
+	if name == "" {
+		return "", "", false
+	}
+	return digest, name, true
+}
+
+// BuildID derives a short, deterministic identifier from a set of build inputs
+// (e.g. source hashes, Go version, build flags). The order of the inputs does
+// not affect the result.
+func BuildID(inputs ...string) string {
+	sorted := append([]string(nil), inputs...)
+	sort.Strings(sorted)
+
+	h := sha256.New()
+	for _, input := range sorted {
+		// Length prefix each input so that distinct sets can't collide by
+		// shifting bytes between neighbouring entries.
+		fmt.Fprintf(h, "%d:%s", len(input), input)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// ModuleHash returns a SHA-256 digest of the go.mod and go.sum files in the
+// current directory, fingerprinting the exact dependency set of the module. A
+// missing go.sum (module without dependencies) is treated as empty.
+func ModuleHash() (string, error) {
+	gomod, err := ioutil.ReadFile("go.mod")
+	if err != nil {
+		return "", err
+	}
+	gosum, err := ioutil.ReadFile("go.sum")
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "go.mod %d\n", len(gomod))
+	h.Write(gomod)
+	fmt.Fprintf(h, "go.sum %d\n", len(gosum))
+	h.Write(gosum)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// AssertReproducible invokes buildFunc twice, each time with a different output
+// path inside a temporary directory, and returns an error if the two produced
+// files differ in their SHA-256 digests.
+func AssertReproducible(buildFunc func(outPath string) error) error {
+	dir, err := ioutil.TempDir("", "build-repro-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	var digests [2]string
+	for i := range digests {
+		out := filepath.Join(dir, fmt.Sprintf("build-%d", i))
+		if err := buildFunc(out); err != nil {
+			return fmt.Errorf("build %d failed: %v", i+1, err)
+		}
+		if digests[i], err = fileSHA256(out); err != nil {
+			return fmt.Errorf("build %d output unreadable: %v", i+1, err)
+		}
+	}
+	if digests[0] != digests[1] {
+		return fmt.Errorf("build is not reproducible: digests %s and %s differ", digests[0], digests[1])
+	}
+	return nil
+}
+
+// HashedName returns path with the first length hex characters of the SHA-256
+// digest of its contents inserted before the extension, e.g. css/style.css
+// becomes css/style.4f3a9c1b.css. Files without extension get the hash appended.
+func HashedName(path string, length int) (string, error) {
+	if length < 1 || length > 2*sha256.Size {
+		return "", fmt.Errorf("invalid hash length %d, want 1-%d", length, 2*sha256.Size)
+	}
+	hash, err := fileSHA256(path)
+	if err != nil {
+		return "", err
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + hash[:length] + ext, nil
+}
+
+// CopyHashed copies src into dstDir under its HashedName, returning the path of
+// the copy.
+func CopyHashed(src, dstDir string, length int, mode os.FileMode) (string, error) {
+	name, err := HashedName(src, length)
+	if err != nil {
+		return "", err
+	}
+	dst := filepath.Join(dstDir, filepath.Base(name))
+	if err := copyFile(dst, src, mode); err != nil {
+		return "", err
+	}
+	return dst, nil
+}
@@ -0,0 +1,244 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Tests that checksum verification reports exactly the tampered entries of a
+// manifest.
+func TestVerifyChecksums(t *testing.T) {
+	dir, err := ioutil.TempDir("", "build-checksum-")
+	if err != nil {
+		t.Fatalf("failed to create temporary dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"geth-linux-amd64.tar.gz": "linux",
+		"geth-darwin-amd64.zip":   "darwin",
+		"geth-windows-amd64.zip":  "windows",
+	}
+	var manifest string
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+		sum := sha256.Sum256([]byte(content))
+		manifest += fmt.Sprintf("%s  %s\n", hex.EncodeToString(sum[:]), name)
+	}
+	manifestPath := filepath.Join(dir, "SHASUMS256.txt")
+	if err := ioutil.WriteFile(manifestPath, []byte(manifest), 0644); err != nil {
+		t.Fatalf("failed to write manifest: %v", err)
+	}
+	if err := VerifyChecksums(manifestPath, dir); err != nil {
+		t.Fatalf("untampered files failed verification: %v", err)
+	}
+	// Tamper with a single file and ensure only that one is reported
+	if err := ioutil.WriteFile(filepath.Join(dir, "geth-darwin-amd64.zip"), []byte("evil"), 0644); err != nil {
+		t.Fatalf("failed to tamper with file: %v", err)
+	}
+	err = VerifyChecksums(manifestPath, dir)
+	if err == nil {
+		t.Fatalf("tampered file passed verification")
+	}
+	for name := range files {
+		if reported := strings.Contains(err.Error(), name); reported != (name == "geth-darwin-amd64.zip") {
+			t.Errorf("file %s: reported %v in error %q", name, reported, err)
+		}
+	}
+}
+
+// Tests that file names with spaces and binary mode markers are supported, and
+// that malformed lines are reported without aborting the verification.
+func TestVerifyChecksumsLineFormat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "build-checksum-")
+	if err != nil {
+		t.Fatalf("failed to create temporary dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"Geth Setup 1.6.1.exe":  "installer",
+		"geth-linux-amd64.tgz":  "linux",
+		"geth-darwin-amd64.zip": "darwin",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	sum := func(content string) string {
+		digest := sha256.Sum256([]byte(content))
+		return hex.EncodeToString(digest[:])
+	}
+	manifest := sum("installer") + "  Geth Setup 1.6.1.exe\n" +
+		strings.ToUpper(sum("linux")) + " *geth-linux-amd64.tgz\r\n" +
+		"# comment\n" +
+		"deadbeef  geth-bogus.zip\n" +
+		sum("evil") + "  geth-darwin-amd64.zip\n"
+
+	manifestPath := filepath.Join(dir, "SHASUMS256.txt")
+	if err := ioutil.WriteFile(manifestPath, []byte(manifest), 0644); err != nil {
+		t.Fatalf("failed to write manifest: %v", err)
+	}
+	err = VerifyChecksums(manifestPath, dir)
+	if err == nil {
+		t.Fatalf("malformed manifest passed verification")
+	}
+	want := "checksum verification failed:\n  line 4: malformed checksum line\n  geth-darwin-amd64.zip: checksum mismatch (have " + sum("darwin") + ", want " + sum("evil") + ")"
+	if err.Error() != want {
+		t.Errorf("error mismatch:\nhave %q\nwant %q", err, want)
+	}
+}
+
+// Tests that build IDs are order independent but input sensitive.
+func TestBuildID(t *testing.T) {
+	id := BuildID("go1.8", "-ldflags=-s", "abcdef")
+	if len(id) != 16 {
+		t.Errorf("build ID length mismatch: have %d, want %d", len(id), 16)
+	}
+	if other := BuildID("abcdef", "go1.8", "-ldflags=-s"); other != id {
+		t.Errorf("reordered inputs changed the ID: have %s, want %s", other, id)
+	}
+	for _, inputs := range [][]string{
+		{"go1.9", "-ldflags=-s", "abcdef"},
+		{"go1.8", "-ldflags=-s"},
+		{"go1.8-ldflags=-s", "abcdef"},
+	} {
+		if other := BuildID(inputs...); other == id {
+			t.Errorf("inputs %q produced colliding ID %s", inputs, id)
+		}
+	}
+}
+
+// Tests that the module hash tracks changes to the dependency set.
+func TestModuleHash(t *testing.T) {
+	dir, err := ioutil.TempDir("", "build-module-")
+	if err != nil {
+		t.Fatalf("failed to create temporary dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get working directory: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("failed to enter module dir: %v", err)
+	}
+	defer os.Chdir(cwd)
+
+	if _, err := ModuleHash(); err == nil {
+		t.Errorf("hash computed without go.mod")
+	}
+	gomod := "module example.com/m\n\nrequire golang.org/x/crypto v0.0.0-20170512130425-ab89591268e0\n"
+	if err := ioutil.WriteFile("go.mod", []byte(gomod), 0644); err != nil {
+		t.Fatalf("failed to write go.mod: %v", err)
+	}
+	gosum := "golang.org/x/crypto v0.0.0-20170512130425-ab89591268e0 h1:AAAA=\n"
+	if err := ioutil.WriteFile("go.sum", []byte(gosum), 0644); err != nil {
+		t.Fatalf("failed to write go.sum: %v", err)
+	}
+	first, err := ModuleHash()
+	if err != nil {
+		t.Fatalf("failed to hash module: %v", err)
+	}
+	if again, _ := ModuleHash(); again != first {
+		t.Errorf("hash not stable: have %s, want %s", again, first)
+	}
+	if err := ioutil.WriteFile("go.sum", []byte(strings.Replace(gosum, "AAAA", "BBBB", 1)), 0644); err != nil {
+		t.Fatalf("failed to modify go.sum: %v", err)
+	}
+	if second, _ := ModuleHash(); second == first {
+		t.Errorf("hash unchanged after modifying go.sum")
+	}
+}
+
+// Tests that deterministic builds pass the reproducibility check while ones
+// embedding the current time fail it.
+func TestAssertReproducible(t *testing.T) {
+	deterministic := func(out string) error {
+		return ioutil.WriteFile(out, []byte("geth"), 0644)
+	}
+	if err := AssertReproducible(deterministic); err != nil {
+		t.Errorf("deterministic build rejected: %v", err)
+	}
+	timestamped := func(out string) error {
+		return ioutil.WriteFile(out, []byte(time.Now().Format(time.RFC3339Nano)), 0644)
+	}
+	if err := AssertReproducible(timestamped); err == nil {
+		t.Errorf("timestamped build accepted")
+	}
+	failing := func(out string) error {
+		return fmt.Errorf("compiler crashed")
+	}
+	if err := AssertReproducible(failing); err == nil || !strings.Contains(err.Error(), "compiler crashed") {
+		t.Errorf("build failure mismatch: have %v, want compiler crashed", err)
+	}
+}
+
+// Tests that hashed names embed a content hash of the requested length, which
+// is stable for identical contents.
+func TestHashedName(t *testing.T) {
+	dir, err := ioutil.TempDir("", "build-hashed-")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for name, content := range map[string]string{"a/style.css": "body{}", "b/style.css": "body{}", "c/style.css": "div{}"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	hash := sha256.Sum256([]byte("body{}"))
+	want := filepath.Join(dir, "a", "style."+hex.EncodeToString(hash[:])[:8]+".css")
+	if have, err := HashedName(filepath.Join(dir, "a", "style.css"), 8); err != nil || have != want {
+		t.Errorf("hashed name mismatch: have %q, %v, want %q", have, err, want)
+	}
+	// Identical contents must get identical names, different ones different names
+	copyA, err := CopyHashed(filepath.Join(dir, "a", "style.css"), filepath.Join(dir, "dist"), 8, 0644)
+	if err != nil {
+		t.Fatalf("failed to copy: %v", err)
+	}
+	copyB, _ := CopyHashed(filepath.Join(dir, "b", "style.css"), filepath.Join(dir, "dist"), 8, 0644)
+	copyC, _ := CopyHashed(filepath.Join(dir, "c", "style.css"), filepath.Join(dir, "dist"), 8, 0644)
+	if copyA != copyB {
+		t.Errorf("identical contents got different names: %s vs %s", copyA, copyB)
+	}
+	if copyA == copyC {
+		t.Errorf("different contents got identical names: %s", copyA)
+	}
+	if content, err := ioutil.ReadFile(copyA); err != nil || string(content) != "body{}" {
+		t.Errorf("copied content mismatch: have %q, %v", content, err)
+	}
+	if _, err := HashedName(filepath.Join(dir, "a", "style.css"), 65); err == nil {
+		t.Errorf("overlong hash length accepted")
+	}
+}
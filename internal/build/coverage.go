@@ -0,0 +1,110 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// coverBlock is a single statement block of a Go coverage profile.
+type coverBlock struct {
+	stmts int
+	count int
+}
+
+// MergeCoverProfiles combines the given Go coverage profiles into a single one,
+// written to output. All inputs must share the same coverage mode. Counts of
+// blocks present in several profiles are summed, except in "set" mode where a
+// block is covered if it was covered by any input.
+func MergeCoverProfiles(inputs []string, output string) error {
+	var (
+		mode   string
+		order  []string
+		blocks = make(map[string]*coverBlock)
+	)
+	for _, input := range inputs {
+		f, err := os.Open(input)
+		if err != nil {
+			return err
+		}
+		scanner := bufio.NewScanner(f)
+		for line := 1; scanner.Scan(); line++ {
+			text := strings.TrimSpace(scanner.Text())
+			if line == 1 {
+				if !strings.HasPrefix(text, "mode: ") {
+					f.Close()
+					return fmt.Errorf("%s: missing mode header", input)
+				}
+				m := strings.TrimPrefix(text, "mode: ")
+				if mode != "" && m != mode {
+					f.Close()
+					return fmt.Errorf("%s: mode mismatch: have %s, want %s", input, m, mode)
+				}
+				mode = m
+				continue
+			}
+			if text == "" {
+				continue
+			}
+			// Block lines are "file:start,end stmts count"
+			fields := strings.Fields(text)
+			if len(fields) != 3 {
+				f.Close()
+				return fmt.Errorf("%s:%d: malformed block %q", input, line, text)
+			}
+			stmts, err1 := strconv.Atoi(fields[1])
+			count, err2 := strconv.Atoi(fields[2])
+			if err1 != nil || err2 != nil {
+				f.Close()
+				return fmt.Errorf("%s:%d: malformed block %q", input, line, text)
+			}
+			block, ok := blocks[fields[0]]
+			if !ok {
+				order = append(order, fields[0])
+				blocks[fields[0]] = &coverBlock{stmts: stmts, count: count}
+				continue
+			}
+			if mode == "set" {
+				if count > block.count {
+					block.count = count
+				}
+			} else {
+				block.count += count
+			}
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", input, err)
+		}
+	}
+	if mode == "" {
+		return fmt.Errorf("no coverage profiles to merge")
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "mode: %s\n", mode)
+	for _, pos := range order {
+		fmt.Fprintf(&buf, "%s %d %d\n", pos, blocks[pos].stmts, blocks[pos].count)
+	}
+	return ioutil.WriteFile(output, buf.Bytes(), 0644)
+}
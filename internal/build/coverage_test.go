@@ -0,0 +1,68 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Tests that overlapping coverage blocks have their counts summed and that
+// profiles of different modes are rejected.
+func TestMergeCoverProfiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "build-cover-")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	profiles := map[string]string{
+		"a.out": "mode: atomic\n" +
+			"example.com/a/a.go:3.14,5.2 1 2\n" +
+			"example.com/a/a.go:7.14,9.2 2 0\n",
+		"b.out": "mode: atomic\n" +
+			"example.com/a/a.go:3.14,5.2 1 3\n" +
+			"example.com/b/b.go:1.10,2.2 1 1\n",
+		"set.out": "mode: set\n" +
+			"example.com/a/a.go:3.14,5.2 1 1\n",
+	}
+	for name, content := range profiles {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	output := filepath.Join(dir, "merged.out")
+	if err := MergeCoverProfiles([]string{filepath.Join(dir, "a.out"), filepath.Join(dir, "b.out")}, output); err != nil {
+		t.Fatalf("failed to merge profiles: %v", err)
+	}
+	have, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatalf("failed to read merged profile: %v", err)
+	}
+	want := "mode: atomic\n" +
+		"example.com/a/a.go:3.14,5.2 1 5\n" +
+		"example.com/a/a.go:7.14,9.2 2 0\n" +
+		"example.com/b/b.go:1.10,2.2 1 1\n"
+	if string(have) != want {
+		t.Errorf("merged profile mismatch:\nhave %q\nwant %q", have, want)
+	}
+	if err := MergeCoverProfiles([]string{filepath.Join(dir, "a.out"), filepath.Join(dir, "set.out")}, output); err == nil {
+		t.Errorf("mismatching coverage modes accepted")
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"fmt"
+	"os/exec"
+	"sort"
+)
+
+// dockerBinary is the docker client used to run containerized build steps.
+var dockerBinary = "docker"
+
+// RunInDocker executes cmd inside a throwaway container of the given image. The
+// mounts map host paths to the container paths they are bind mounted at.
+func RunInDocker(image string, mounts map[string]string, cmd []string) error {
+	docker, err := exec.LookPath(dockerBinary)
+	if err != nil {
+		return fmt.Errorf("docker not available: %v", err)
+	}
+	return runCommand(exec.Command(docker, dockerRunArgs(image, mounts, cmd)...))
+}
+
+// dockerRunArgs assembles the docker run arguments, ordering the mounts by host
+// path to keep the command line deterministic.
+func dockerRunArgs(image string, mounts map[string]string, cmd []string) []string {
+	hosts := make([]string, 0, len(mounts))
+	for host := range mounts {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	args := []string{"run", "--rm"}
+	for _, host := range hosts {
+		args = append(args, "-v", host+":"+mounts[host])
+	}
+	args = append(args, image)
+	return append(args, cmd...)
+}
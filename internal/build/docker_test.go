@@ -0,0 +1,57 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Tests that the docker invocation contains the mounts, image and command.
+func TestRunInDocker(t *testing.T) {
+	skipNoShell(t)
+
+	dir, err := ioutil.TempDir("", "build-docker-")
+	if err != nil {
+		t.Fatalf("failed to create temporary dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(docker string) { dockerBinary = docker }(dockerBinary)
+	dockerBinary = filepath.Join(dir, "missing-docker")
+	if err := RunInDocker("golang:1.8", nil, []string{"go", "version"}); err == nil {
+		t.Errorf("missing docker not reported")
+	}
+	dockerBinary = writeStub(t, dir, "docker", `echo "$@" > "$(dirname "$0")/args"`)
+	mounts := map[string]string{
+		"/src/go-ethereum": "/go/src/github.com/ethereum/go-ethereum",
+		"/cache":           "/root/.cache",
+	}
+	if err := RunInDocker("golang:1.8", mounts, []string{"go", "run", "build/ci.go", "install"}); err != nil {
+		t.Fatalf("failed to run docker: %v", err)
+	}
+	args, err := ioutil.ReadFile(filepath.Join(dir, "args"))
+	if err != nil {
+		t.Fatalf("failed to read docker arguments: %v", err)
+	}
+	want := "run --rm -v /cache:/root/.cache -v /src/go-ethereum:/go/src/github.com/ethereum/go-ethereum golang:1.8 go run build/ci.go install\n"
+	if string(args) != want {
+		t.Errorf("docker arguments mismatch:\nhave %q\nwant %q", args, want)
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// GenerateEmbed writes a Go source file for package pkg into outputFile, which
+// declares varName as a map[string][]byte holding the contents of all files
+// below srcDir, keyed by their slash separated path relative to srcDir.
+func GenerateEmbed(srcDir, outputFile, pkg, varName string) error {
+	assets := make(map[string][]byte)
+	err := filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		assets[filepath.ToSlash(rel)] = content
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(assets))
+	for name := range assets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by internal/build from %s. DO NOT EDIT.\n\n", filepath.ToSlash(srcDir))
+	fmt.Fprintf(&buf, "package %s\n\n", pkg)
+	fmt.Fprintf(&buf, "var %s = map[string][]byte{\n", varName)
+	for _, name := range names {
+		fmt.Fprintf(&buf, "%s: []byte(%s),\n", strconv.Quote(name), strconv.Quote(string(assets[name])))
+	}
+	fmt.Fprintf(&buf, "}\n")
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("generated invalid source: %v", err)
+	}
+	return ioutil.WriteFile(outputFile, src, 0644)
+}
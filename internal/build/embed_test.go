@@ -0,0 +1,77 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// Tests that the generated asset file compiles and maps every file of the
+// source tree to its contents.
+func TestGenerateEmbed(t *testing.T) {
+	root := newTestTree(t, map[string]os.FileMode{
+		"assets/index.html":   0644,
+		"assets/js/bundle.js": 0644,
+		"assets/img/logo.bin": 0644,
+	})
+	defer os.RemoveAll(root)
+
+	binary := []byte{0x00, 0xff, '"', '\n', '`'}
+	if err := ioutil.WriteFile(filepath.Join(root, "assets", "img", "logo.bin"), binary, 0644); err != nil {
+		t.Fatalf("failed to write binary asset: %v", err)
+	}
+	out := filepath.Join(root, "assets.go")
+	if err := GenerateEmbed(filepath.Join(root, "assets"), out, "dashboard", "Assets"); err != nil {
+		t.Fatalf("failed to generate embed file: %v", err)
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, out, nil, 0)
+	if err != nil {
+		t.Fatalf("failed to parse generated file: %v", err)
+	}
+	if _, err := new(types.Config).Check("dashboard", fset, []*ast.File{file}, nil); err != nil {
+		t.Fatalf("generated file doesn't compile: %v", err)
+	}
+	have := make(map[string]string)
+	ast.Inspect(file, func(n ast.Node) bool {
+		kv, ok := n.(*ast.KeyValueExpr)
+		if !ok {
+			return true
+		}
+		key, _ := strconv.Unquote(kv.Key.(*ast.BasicLit).Value)
+		value, _ := strconv.Unquote(kv.Value.(*ast.CallExpr).Args[0].(*ast.BasicLit).Value)
+		have[key] = value
+		return false
+	})
+	want := map[string]string{
+		"index.html":   "assets/index.html",
+		"js/bundle.js": "assets/js/bundle.js",
+		"img/logo.bin": string(binary),
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("embedded assets mismatch:\nhave %q\nwant %q", have, want)
+	}
+}
@@ -79,6 +79,29 @@ func Env() Environment {
 	}
 }
 
+// CIProvider returns the name of the CI service the build is running on
+// ("github", "gitlab", "travis" or "appveyor"), or an empty string if the build
+// is running locally.
+func CIProvider() string {
+	switch {
+	case os.Getenv("GITHUB_ACTIONS") == "true":
+		return "github"
+	case os.Getenv("GITLAB_CI") == "true":
+		return "gitlab"
+	case os.Getenv("CI") == "true" && os.Getenv("TRAVIS") == "true":
+		return "travis"
+	case os.Getenv("CI") == "True" && os.Getenv("APPVEYOR") == "True":
+		return "appveyor"
+	default:
+		return ""
+	}
+}
+
+// IsCI reports whether the build is running on a CI service.
+func IsCI() bool {
+	return CIProvider() != ""
+}
+
 // LocalEnv returns build environment metadata gathered from git.
 func LocalEnv() Environment {
 	env := applyEnvFlags(Environment{Name: "local", Repo: "ethereum/go-ethereum"})
@@ -0,0 +1,287 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// AssertTreeModes walks the file tree rooted at root and returns an error
+// listing all regular files whose permissions differ from fileMode and all
+// directories (including root) whose permissions differ from dirMode.
+func AssertTreeModes(root string, fileMode, dirMode os.FileMode) error {
+	var mismatches []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		want := fileMode
+		switch {
+		case info.IsDir():
+			want = dirMode
+		case !info.Mode().IsRegular():
+			return nil
+		}
+		if have := info.Mode().Perm(); have != want {
+			mismatches = append(mismatches, fmt.Sprintf("%s: have %v, want %v", path, have, want))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(mismatches) > 0 {
+		return fmt.Errorf("unexpected permissions:\n  %s", strings.Join(mismatches, "\n  "))
+	}
+	return nil
+}
+
+// SafeJoin joins rel onto root, ensuring that the resulting path stays within
+// root. Absolute paths and paths escaping root via ".." are rejected.
+func SafeJoin(root, rel string) (string, error) {
+	if filepath.IsAbs(rel) || filepath.VolumeName(rel) != "" {
+		return "", fmt.Errorf("path %q is not relative", rel)
+	}
+	clean := filepath.Clean(rel)
+	if clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %q escapes %s", rel, root)
+	}
+	return filepath.Join(root, clean), nil
+}
+
+// ChmodTree walks the file tree rooted at root, setting the permissions of all
+// regular files to fileMode and of all directories (including root) to dirMode.
+// Directory permissions are applied after their contents were processed, so a
+// restrictive dirMode doesn't prevent the walk from descending.
+func ChmodTree(root string, fileMode, dirMode os.FileMode) error {
+	var dirs []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		switch {
+		case info.IsDir():
+			dirs = append(dirs, path)
+		case info.Mode().IsRegular():
+			return os.Chmod(path, fileMode)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chmod(dirs[i], dirMode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CopyIfNewer copies src to dst only if dst does not exist or its modification
+// time is older than that of src. It reports whether a copy was made.
+func CopyIfNewer(dst, src string, mode os.FileMode) (bool, error) {
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return false, err
+	}
+	dstInfo, err := os.Stat(dst)
+	switch {
+	case err == nil && !srcInfo.ModTime().After(dstInfo.ModTime()):
+		return false, nil
+	case err != nil && !os.IsNotExist(err):
+		return false, err
+	}
+	if err := copyFile(dst, src, mode); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// CopyFileOwned copies src to dst like CopyFile and changes the ownership of
+// the copy to the given user and group. Changing ownership generally requires
+// root privileges and is not supported on Windows.
+func CopyFileOwned(dst, src string, mode os.FileMode, uid, gid int) error {
+	if err := copyFile(dst, src, mode); err != nil {
+		return err
+	}
+	if err := os.Chown(dst, uid, gid); err != nil {
+		return fmt.Errorf("can't set ownership of %s: %v", dst, err)
+	}
+	return nil
+}
+
+// copyBufferSize is the size of the buffers used by CopyFileBuffered.
+const copyBufferSize = 32 * 1024
+
+var (
+	copyBufferPool = sync.Pool{
+		New: func() interface{} {
+			atomic.AddUint64(&copyBufferAllocs, 1)
+			buf := make([]byte, copyBufferSize)
+			return &buf // Pointer to avoid allocating a slice header on Put
+		},
+	}
+	copyBufferGets   uint64 // Number of buffers drawn from copyBufferPool
+	copyBufferAllocs uint64 // Number of buffers newly allocated by the pool
+)
+
+// CopyFileBuffered copies a file like CopyFile, but through a buffer drawn from
+// a shared pool. This avoids allocating a fresh buffer for every copy when lots
+// of (small) files are copied.
+func CopyFileBuffered(dst, src string, mode os.FileMode) {
+	atomic.AddUint64(&copyBufferGets, 1)
+	buf := copyBufferPool.Get().(*[]byte)
+	defer copyBufferPool.Put(buf)
+
+	if err := copyFileBuffer(dst, src, mode, *buf); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// LayoutFHS arranges files into a filesystem hierarchy standard tree under root,
+// as consumed by distribution packaging tools. Both maps are keyed by the target
+// path (relative to usr/bin and usr/share respectively) and valued by the source
+// file to copy. Binaries are installed with mode 0755, share files with 0644.
+func LayoutFHS(root string, binaries, shareFiles map[string]string) error {
+	install := func(dir string, files map[string]string, mode os.FileMode) error {
+		for name, src := range files {
+			dst, err := SafeJoin(filepath.Join(root, dir), name)
+			if err != nil {
+				return err
+			}
+			if err := copyFile(dst, src, mode); err != nil {
+				return err
+			}
+			// Enforce the mode regardless of umask and preexisting files
+			if err := os.Chmod(dst, mode); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := install(filepath.Join("usr", "bin"), binaries, 0755); err != nil {
+		return err
+	}
+	return install(filepath.Join("usr", "share"), shareFiles, 0644)
+}
+
+// Outdated reports whether output needs to be rebuilt, which is the case if it
+// doesn't exist or if any of the inputs was modified after it.
+func Outdated(output string, inputs ...string) (bool, error) {
+	outInfo, err := os.Stat(output)
+	if os.IsNotExist(err) {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+	for _, input := range inputs {
+		info, err := os.Stat(input)
+		if err != nil {
+			return false, err
+		}
+		if info.ModTime().After(outInfo.ModTime()) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// CopyScript copies a script file, rewriting its shebang line (if it has one) to
+// invoke the given interpreter instead. The rest of the script is copied as is.
+func CopyScript(dst, src, interpreter string, mode os.FileMode) error {
+	content, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if bytes.HasPrefix(content, []byte("#!")) {
+		body := []byte{}
+		if idx := bytes.IndexByte(content, '\n'); idx >= 0 {
+			body = content[idx:]
+		}
+		content = append([]byte("#!"+interpreter), body...)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(dst, content, mode); err != nil {
+		return err
+	}
+	return os.Chmod(dst, mode)
+}
+
+// RequireDiskSpace returns an error if the filesystem containing dir has less
+// than the given number of bytes available, failing a build upfront instead of
+// midway with a full disk.
+func RequireDiskSpace(dir string, bytes int64) error {
+	free, err := freeDiskSpace(dir)
+	if err != nil {
+		return fmt.Errorf("can't determine free space of %s: %v", dir, err)
+	}
+	if bytes > 0 && free < uint64(bytes) {
+		return fmt.Errorf("insufficient disk space in %s: have %d bytes, want %d", dir, free, bytes)
+	}
+	return nil
+}
+
+// WriteDepFile writes a make dependency fragment into depPath, declaring that
+// target depends on all the given files. Each dependency is placed on its own
+// continuation line, with characters special to make escaped.
+func WriteDepFile(depPath, target string, deps []string) error {
+	var buf bytes.Buffer
+	buf.WriteString(escapeMakePath(target) + ":")
+	for _, dep := range deps {
+		buf.WriteString(" \\\n  " + escapeMakePath(dep))
+	}
+	buf.WriteString("\n")
+
+	if err := os.MkdirAll(filepath.Dir(depPath), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(depPath, buf.Bytes(), 0644)
+}
+
+// escapeMakePath escapes a path for use in a make rule.
+func escapeMakePath(path string) string {
+	path = strings.Replace(path, "$", "$$", -1)
+	path = strings.Replace(path, "#", "\\#", -1)
+	return strings.Replace(path, " ", "\\ ", -1)
+}
+
+// TouchesAny reports whether any of the changed files (e.g. from git diff
+// --name-only) lies within one of the given directories. Paths are compared by
+// whole components, so a change to "core2/x.go" doesn't touch "core".
+func TouchesAny(changedFiles []string, dirs []string) bool {
+	for _, dir := range dirs {
+		dir = filepath.ToSlash(filepath.Clean(dir))
+		for _, file := range changedFiles {
+			file = filepath.ToSlash(filepath.Clean(file))
+			if dir == "." || file == dir || strings.HasPrefix(file, dir+"/") {
+				return true
+			}
+		}
+	}
+	return false
+}
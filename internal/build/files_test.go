@@ -0,0 +1,409 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestTree creates a temporary directory populated with the given files (in
+// slash separated form relative to the root), each with the requested mode.
+func newTestTree(t *testing.T, files map[string]os.FileMode) string {
+	root, err := ioutil.TempDir("", "build-tree-")
+	if err != nil {
+		t.Fatalf("failed to create temporary dir: %v", err)
+	}
+	for name, mode := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatalf("failed to create parent of %s: %v", name, err)
+		}
+		if err := ioutil.WriteFile(path, []byte(name), mode); err != nil {
+			t.Fatalf("failed to create %s: %v", name, err)
+		}
+		if err := os.Chmod(path, mode); err != nil {
+			t.Fatalf("failed to chmod %s: %v", name, err)
+		}
+	}
+	return root
+}
+
+// Tests that all files and directories of a tree get their modes normalized.
+func TestChmodTree(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix permissions not supported")
+	}
+	root := newTestTree(t, map[string]os.FileMode{
+		"README":             0600,
+		"bin/geth":           0700,
+		"share/doc/LICENSE":  0666,
+		"share/man/geth.1":   0640,
+		"share/man/evm.1":    0444,
+		"scripts/install.sh": 0777,
+	})
+	defer os.RemoveAll(root)
+
+	if err := ChmodTree(root, 0644, 0755); err != nil {
+		t.Fatalf("failed to chmod tree: %v", err)
+	}
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		want := os.FileMode(0644)
+		if info.IsDir() {
+			want = 0755
+		}
+		if have := info.Mode().Perm(); have != want {
+			t.Errorf("%s: mode mismatch: have %v, want %v", path, have, want)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to walk tree: %v", err)
+	}
+}
+
+// Tests that joined paths are confined to their root directory.
+func TestSafeJoin(t *testing.T) {
+	root := filepath.Join(os.TempDir(), "build-root")
+
+	tests := []struct {
+		rel  string
+		want string
+		fail bool
+	}{
+		{rel: "pkg/gen.go", want: filepath.Join(root, "pkg", "gen.go")},
+		{rel: "pkg/../gen.go", want: filepath.Join(root, "gen.go")},
+		{rel: "../escape", fail: true},
+		{rel: "pkg/../../escape", fail: true},
+		{rel: filepath.Join(root, "abs.go"), fail: true},
+	}
+	for i, tt := range tests {
+		have, err := SafeJoin(root, tt.rel)
+		if tt.fail {
+			if err == nil {
+				t.Errorf("test %d: path %q accepted as %q", i, tt.rel, have)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: path %q rejected: %v", i, tt.rel, err)
+		} else if have != tt.want {
+			t.Errorf("test %d: path mismatch: have %q, want %q", i, have, tt.want)
+		}
+	}
+}
+
+// Tests that files are only copied over missing or stale destinations.
+func TestCopyIfNewer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "build-copy-")
+	if err != nil {
+		t.Fatalf("failed to create temporary dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	src, dst := filepath.Join(dir, "src"), filepath.Join(dir, "out", "dst")
+	now := time.Now()
+
+	tests := []struct {
+		content string
+		srcTime time.Time
+		dstTime time.Time // zero if dst should not be touched
+		copied  bool
+		want    string
+	}{
+		{content: "first", srcTime: now, copied: true, want: "first"},
+		{content: "second", srcTime: now.Add(time.Hour), dstTime: now, copied: true, want: "second"},
+		{content: "third", srcTime: now.Add(-time.Hour), dstTime: now, copied: false, want: "second"},
+	}
+	for i, tt := range tests {
+		if err := ioutil.WriteFile(src, []byte(tt.content), 0644); err != nil {
+			t.Fatalf("test %d: failed to write source: %v", i, err)
+		}
+		if err := os.Chtimes(src, tt.srcTime, tt.srcTime); err != nil {
+			t.Fatalf("test %d: failed to set source time: %v", i, err)
+		}
+		if !tt.dstTime.IsZero() {
+			if err := os.Chtimes(dst, tt.dstTime, tt.dstTime); err != nil {
+				t.Fatalf("test %d: failed to set destination time: %v", i, err)
+			}
+		}
+		copied, err := CopyIfNewer(dst, src, 0644)
+		if err != nil {
+			t.Fatalf("test %d: failed to copy: %v", i, err)
+		}
+		if copied != tt.copied {
+			t.Errorf("test %d: copy mismatch: have %v, want %v", i, copied, tt.copied)
+		}
+		if have, err := ioutil.ReadFile(dst); err != nil {
+			t.Errorf("test %d: failed to read destination: %v", i, err)
+		} else if string(have) != tt.want {
+			t.Errorf("test %d: content mismatch: have %q, want %q", i, have, tt.want)
+		}
+	}
+}
+
+// Tests that binaries and share files are placed at their FHS locations with
+// the correct permissions.
+func TestLayoutFHS(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix permissions not supported")
+	}
+	src := newTestTree(t, map[string]os.FileMode{
+		"build/bin/geth": 0700,
+		"build/bin/evm":  0700,
+		"docs/geth.1":    0600,
+		"COPYING":        0600,
+	})
+	defer os.RemoveAll(src)
+
+	root := filepath.Join(src, "pkg")
+	err := LayoutFHS(root, map[string]string{
+		"geth": filepath.Join(src, "build", "bin", "geth"),
+		"evm":  filepath.Join(src, "build", "bin", "evm"),
+	}, map[string]string{
+		"man/man1/geth.1":        filepath.Join(src, "docs", "geth.1"),
+		"doc/ethereum/copyright": filepath.Join(src, "COPYING"),
+	})
+	if err != nil {
+		t.Fatalf("failed to lay out tree: %v", err)
+	}
+	want := map[string]os.FileMode{
+		"usr/bin/geth":                     0755,
+		"usr/bin/evm":                      0755,
+		"usr/share/man/man1/geth.1":        0644,
+		"usr/share/doc/ethereum/copyright": 0644,
+	}
+	for name, mode := range want {
+		info, err := os.Stat(filepath.Join(root, filepath.FromSlash(name)))
+		if err != nil {
+			t.Errorf("%s: missing from layout: %v", name, err)
+			continue
+		}
+		if info.Mode().Perm() != mode {
+			t.Errorf("%s: mode mismatch: have %v, want %v", name, info.Mode().Perm(), mode)
+		}
+	}
+}
+
+// Tests that tree mode verification reports exactly the deviating files.
+func TestAssertTreeModes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix permissions not supported")
+	}
+	root := newTestTree(t, map[string]os.FileMode{
+		"README":        0644,
+		"bin/geth":      0644,
+		"share/LICENSE": 0644,
+	})
+	defer os.RemoveAll(root)
+
+	if err := ChmodTree(root, 0644, 0755); err != nil {
+		t.Fatalf("failed to chmod tree: %v", err)
+	}
+	if err := AssertTreeModes(root, 0644, 0755); err != nil {
+		t.Fatalf("normalized tree rejected: %v", err)
+	}
+	if err := os.Chmod(filepath.Join(root, "bin", "geth"), 0755); err != nil {
+		t.Fatalf("failed to chmod file: %v", err)
+	}
+	err := AssertTreeModes(root, 0644, 0755)
+	if err == nil {
+		t.Fatalf("mis-moded file accepted")
+	}
+	if have := strings.Count(err.Error(), "\n"); have != 1 {
+		t.Errorf("mismatch count: have %d, want %d: %v", have, 1, err)
+	}
+	if !strings.Contains(err.Error(), filepath.Join("bin", "geth")) {
+		t.Errorf("error doesn't name the mis-moded file: %v", err)
+	}
+}
+
+// Tests that outputs are considered outdated if missing or older than an input.
+func TestOutdated(t *testing.T) {
+	root := newTestTree(t, map[string]os.FileMode{"a.go": 0644, "b.go": 0644})
+	defer os.RemoveAll(root)
+
+	var (
+		output = filepath.Join(root, "out.bin")
+		a      = filepath.Join(root, "a.go")
+		b      = filepath.Join(root, "b.go")
+		now    = time.Now()
+	)
+	if outdated, err := Outdated(output, a, b); err != nil || !outdated {
+		t.Errorf("missing output: have %v, %v, want outdated", outdated, err)
+	}
+	if err := ioutil.WriteFile(output, nil, 0644); err != nil {
+		t.Fatalf("failed to write output: %v", err)
+	}
+	os.Chtimes(a, now.Add(-2*time.Hour), now.Add(-2*time.Hour))
+	os.Chtimes(b, now.Add(-2*time.Hour), now.Add(-2*time.Hour))
+	os.Chtimes(output, now.Add(-time.Hour), now.Add(-time.Hour))
+	if outdated, err := Outdated(output, a, b); err != nil || outdated {
+		t.Errorf("fresh output: have %v, %v, want up to date", outdated, err)
+	}
+	os.Chtimes(b, now, now)
+	if outdated, err := Outdated(output, a, b); err != nil || !outdated {
+		t.Errorf("stale output: have %v, %v, want outdated", outdated, err)
+	}
+	if _, err := Outdated(output, filepath.Join(root, "missing.go")); err == nil {
+		t.Errorf("missing input not reported")
+	}
+}
+
+// Tests that script shebang lines are rewritten while the body is preserved.
+func TestCopyScript(t *testing.T) {
+	dir, err := ioutil.TempDir("", "build-script-")
+	if err != nil {
+		t.Fatalf("failed to create temporary dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		script string
+		want   string
+	}{
+		{"#!/usr/bin/env python\nprint('hi')\n", "#!/usr/bin/python3\nprint('hi')\n"},
+		{"#!/bin/sh", "#!/usr/bin/python3"},
+		{"print('no shebang')\n", "print('no shebang')\n"},
+	}
+	for i, tt := range tests {
+		src := filepath.Join(dir, "src.py")
+		dst := filepath.Join(dir, "out", "dst.py")
+		if err := ioutil.WriteFile(src, []byte(tt.script), 0644); err != nil {
+			t.Fatalf("test %d: failed to write script: %v", i, err)
+		}
+		if err := CopyScript(dst, src, "/usr/bin/python3", 0755); err != nil {
+			t.Fatalf("test %d: failed to copy script: %v", i, err)
+		}
+		if have, _ := ioutil.ReadFile(dst); string(have) != tt.want {
+			t.Errorf("test %d: script mismatch: have %q, want %q", i, have, tt.want)
+		}
+	}
+}
+
+// Tests that disk space requirements are checked against the filesystem.
+func TestRequireDiskSpace(t *testing.T) {
+	switch runtime.GOOS {
+	case "darwin", "dragonfly", "freebsd", "linux", "windows":
+	default:
+		t.Skip("free disk space query not supported")
+	}
+	dir := os.TempDir()
+	if err := RequireDiskSpace(dir, 1024); err != nil {
+		t.Errorf("small requirement rejected: %v", err)
+	}
+	if err := RequireDiskSpace(dir, 1<<62); err == nil {
+		t.Errorf("absurd requirement accepted")
+	}
+}
+
+// Tests the formatting and escaping of make dependency files.
+func TestWriteDepFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "build-dep-")
+	if err != nil {
+		t.Fatalf("failed to create temporary dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "gen.go.d")
+	deps := []string{"templates/gen.tmpl", "My Documents/data.json", "cost$.txt"}
+	if err := WriteDepFile(path, "gen.go", deps); err != nil {
+		t.Fatalf("failed to write dep file: %v", err)
+	}
+	want := "gen.go: \\\n  templates/gen.tmpl \\\n  My\\ Documents/data.json \\\n  cost$$.txt\n"
+	if have, _ := ioutil.ReadFile(path); string(have) != want {
+		t.Errorf("dep file mismatch:\nhave %q\nwant %q", have, want)
+	}
+}
+
+// Tests that changed files are matched against watched directories by whole
+// path components.
+func TestTouchesAny(t *testing.T) {
+	dirs := []string{"core", "eth/downloader/"}
+	tests := []struct {
+		changed []string
+		want    bool
+	}{
+		{[]string{"README.md", "core/blockchain.go"}, true},
+		{[]string{"eth/downloader/queue.go"}, true},
+		{[]string{"./core/types/block.go"}, true},
+		{[]string{"corelib/x.go", "eth/downloader2/y.go"}, false},
+		{[]string{"cmd/geth/main.go", "eth/handler.go"}, false},
+		{nil, false},
+	}
+	for i, tt := range tests {
+		if have := TouchesAny(tt.changed, dirs); have != tt.want {
+			t.Errorf("test %d: touch mismatch for %v: have %v, want %v", i, tt.changed, have, tt.want)
+		}
+	}
+}
+
+// Tests that buffered copies produce identical files and reuse pooled buffers.
+func TestCopyFileBuffered(t *testing.T) {
+	root := newTestTree(t, map[string]os.FileMode{"src/geth": 0755})
+	defer os.RemoveAll(root)
+
+	content := strings.Repeat("geth", 3*copyBufferSize/4+1)
+	src := filepath.Join(root, "src", "geth")
+	if err := ioutil.WriteFile(src, []byte(content), 0755); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+	gets, allocs := atomic.LoadUint64(&copyBufferGets), atomic.LoadUint64(&copyBufferAllocs)
+	for i := 0; i < 20; i++ {
+		dst := filepath.Join(root, "dst", fmt.Sprintf("geth-%d", i))
+		CopyFileBuffered(dst, src, 0755)
+		if have, _ := ioutil.ReadFile(dst); string(have) != content {
+			t.Fatalf("copy %d: content mismatch: have %d bytes, want %d", i, len(have), len(content))
+		}
+	}
+	gets, allocs = atomic.LoadUint64(&copyBufferGets)-gets, atomic.LoadUint64(&copyBufferAllocs)-allocs
+	if gets != 20 {
+		t.Errorf("buffer get count mismatch: have %d, want 20", gets)
+	}
+	if hits := gets - allocs; hits == 0 {
+		t.Errorf("no pooled buffers reused across %d copies", gets)
+	}
+}
+
+// Benchmarks copying small files through the buffer pool.
+func BenchmarkCopyFileBuffered(b *testing.B) {
+	dir, err := ioutil.TempDir("", "build-copy-")
+	if err != nil {
+		b.Fatalf("failed to create temporary dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "src")
+	if err := ioutil.WriteFile(src, []byte("small file"), 0644); err != nil {
+		b.Fatalf("failed to write source: %v", err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		CopyFileBuffered(filepath.Join(dir, "dst"), src, 0644)
+	}
+}
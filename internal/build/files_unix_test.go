@@ -0,0 +1,55 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build darwin dragonfly freebsd linux
+
+package build
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// Tests that copied files are owned by the requested user and group.
+func TestCopyFileOwned(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("changing file ownership requires root")
+	}
+	root := newTestTree(t, map[string]os.FileMode{"geth": 0755})
+	defer os.RemoveAll(root)
+
+	dst := filepath.Join(root, "usr", "bin", "geth")
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		t.Fatalf("failed to create destination dir: %v", err)
+	}
+	if err := CopyFileOwned(dst, filepath.Join(root, "geth"), 0755, 1, 2); err != nil {
+		t.Fatalf("failed to copy file: %v", err)
+	}
+	info, err := os.Stat(dst)
+	if err != nil {
+		t.Fatalf("failed to stat copy: %v", err)
+	}
+	stat := info.Sys().(*syscall.Stat_t)
+	if stat.Uid != 1 || stat.Gid != 2 {
+		t.Errorf("ownership mismatch: have %d:%d, want 1:2", stat.Uid, stat.Gid)
+	}
+	if content, _ := ioutil.ReadFile(dst); len(content) == 0 {
+		t.Errorf("copied file is empty")
+	}
+}
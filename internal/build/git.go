@@ -0,0 +1,201 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+)
+
+// tryGit runs a git subcommand and returns its trimmed output. Unlike RunGit,
+// failures are reported to the caller instead of terminating the process.
+func tryGit(args ...string) (string, error) {
+	cmd := exec.Command(gitBinary, args...)
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// GitRemoteURL returns the configured URL of the given git remote, or an empty
+// string if git is not available or the remote does not exist.
+func GitRemoteURL(remote string) string {
+	url, err := tryGit("config", "--get", "remote."+remote+".url")
+	if err != nil {
+		return ""
+	}
+	return url
+}
+
+// gitStatus returns the lines of `git status --porcelain`, each naming a file
+// which is modified, staged or untracked in the working tree.
+func gitStatus() ([]string, error) {
+	cmd := exec.Command(gitBinary, "status", "--porcelain")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.TrimSpace(line) != "" {
+			files = append(files, line)
+		}
+	}
+	return files, nil
+}
+
+// GitDirty reports whether the working tree has modified, staged or untracked
+// files. It returns false if git is not available.
+func GitDirty() bool {
+	files, err := gitStatus()
+	return err == nil && len(files) > 0
+}
+
+// RequireCleanTree returns an error listing the offending files if the working
+// tree is not clean, or if its state cannot be determined.
+func RequireCleanTree() error {
+	files, err := gitStatus()
+	if err != nil {
+		return fmt.Errorf("can't determine working tree state: %v", err)
+	}
+	if len(files) > 0 {
+		return fmt.Errorf("working tree is not clean:\n  %s", strings.Join(files, "\n  "))
+	}
+	return nil
+}
+
+// UntrackedFiles returns the files in the working tree which are neither tracked
+// nor ignored by git. The result is empty if git is not available.
+func UntrackedFiles() []string {
+	out, err := tryGit("ls-files", "--others", "--exclude-standard")
+	if err != nil || out == "" {
+		return nil
+	}
+	return strings.Split(out, "\n")
+}
+
+// GitTags returns the names of all tags in the repository.
+func GitTags() ([]string, error) {
+	out, err := tryGit("tag", "--list")
+	if err != nil {
+		return nil, err
+	}
+	if out == "" {
+		return nil, nil
+	}
+	return strings.Split(out, "\n"), nil
+}
+
+// ReleaseTag creates an annotated tag for the given version on the current
+// commit and, if push is set, pushes it to origin. It refuses to tag a dirty
+// working tree or to replace an existing tag.
+func ReleaseTag(version, message string, push bool) error {
+	if err := RequireCleanTree(); err != nil {
+		return fmt.Errorf("can't tag release: %v", err)
+	}
+	tags, err := GitTags()
+	if err != nil {
+		return fmt.Errorf("can't list tags: %v", err)
+	}
+	for _, tag := range tags {
+		if tag == version {
+			return fmt.Errorf("tag %s already exists", version)
+		}
+	}
+	if err := runCommand(exec.Command(gitBinary, "tag", "-a", version, "-m", message)); err != nil {
+		return fmt.Errorf("failed to create tag %s: %v", version, err)
+	}
+	if push {
+		if err := runCommand(exec.Command(gitBinary, "push", "origin", version)); err != nil {
+			return fmt.Errorf("failed to push tag %s: %v", version, err)
+		}
+	}
+	return nil
+}
+
+// RequireTracked returns an error listing the files below dir which are neither
+// tracked nor ignored by git, ensuring archives of dir only contain committed
+// content.
+func RequireTracked(dir string) error {
+	out, err := tryGit("ls-files", "--others", "--exclude-standard", "--", dir)
+	if err != nil {
+		return fmt.Errorf("can't list untracked files: %v", err)
+	}
+	if out != "" {
+		return fmt.Errorf("%s contains untracked files:\n  %s", dir, strings.Replace(out, "\n", "\n  ", -1))
+	}
+	return nil
+}
+
+// GitArchive creates a source archive of the given ref at output using git
+// archive, with all entries placed under prefix (e.g. "geth-1.6.1/"). The
+// archive format is derived from the output extension (.tar, .tar.gz, .tgz or
+// .zip). Paths marked export-ignore in .gitattributes are excluded.
+func GitArchive(ref, prefix, output string) error {
+	args := []string{"archive", "-o", output}
+	if prefix != "" {
+		args = append(args, "--prefix="+prefix)
+	}
+	if err := runCommand(exec.Command(gitBinary, append(args, ref)...)); err != nil {
+		return fmt.Errorf("git archive of %s failed: %v", ref, err)
+	}
+	return nil
+}
+
+// ExportFiles returns the files git archive would include for the given ref,
+// i.e. the tracked files less those marked export-ignore.
+func ExportFiles(ref string) ([]string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command(gitBinary, "archive", "--format=tar", ref)
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	var (
+		files []string
+		tr    = tar.NewReader(stdout)
+	)
+	for {
+		head, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			cmd.Process.Kill()
+			cmd.Wait()
+			return nil, fmt.Errorf("can't read git archive of %s: %v", ref, err)
+		}
+		if head.Typeflag != tar.TypeDir && head.Typeflag != tar.TypeXGlobalHeader {
+			files = append(files, head.Name)
+		}
+	}
+	io.Copy(ioutil.Discard, stdout)
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("git archive of %s failed: %v\n%s", ref, err, strings.TrimSpace(stderr.String()))
+	}
+	return files, nil
+}
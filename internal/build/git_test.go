@@ -0,0 +1,249 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newTestRepo creates a fresh git repository in a temporary directory, switches
+// the working directory into it and points the git helpers at the host's git.
+// The returned function restores the previous state and removes the repository.
+func newTestRepo(t *testing.T) (string, func()) {
+	git, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("git not found in PATH")
+	}
+	dir, err := ioutil.TempDir("", "build-git-")
+	if err != nil {
+		t.Fatalf("failed to create temporary repo dir: %v", err)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get working directory: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("failed to enter temporary repo dir: %v", err)
+	}
+	oldGit := gitBinary
+	gitBinary = git
+
+	cleanup := func() {
+		gitBinary = oldGit
+		os.Chdir(cwd)
+		os.RemoveAll(dir)
+	}
+	if err := runTestGit("init", "-q"); err != nil {
+		cleanup()
+		t.Fatalf("failed to initialize repo: %v", err)
+	}
+	return dir, cleanup
+}
+
+// runTestGit runs a git command in the current directory with a fixed identity.
+func runTestGit(args ...string) error {
+	args = append([]string{"-c", "user.name=tester", "-c", "user.email=tester@example.com"}, args...)
+	if out, err := exec.Command(gitBinary, args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, out)
+	}
+	return nil
+}
+
+// Tests that the URL of a configured remote is returned and that missing
+// remotes yield an empty result.
+func TestGitRemoteURL(t *testing.T) {
+	_, cleanup := newTestRepo(t)
+	defer cleanup()
+
+	want := "https://github.com/ethereum/go-ethereum.git"
+	if err := runTestGit("remote", "add", "origin", want); err != nil {
+		t.Fatalf("failed to add remote: %v", err)
+	}
+	if have := GitRemoteURL("origin"); have != want {
+		t.Errorf("origin URL mismatch: have %q, want %q", have, want)
+	}
+	if have := GitRemoteURL("upstream"); have != "" {
+		t.Errorf("missing remote URL mismatch: have %q, want empty", have)
+	}
+}
+
+// commitTestFile writes a file into the current repository and commits it.
+func commitTestFile(t *testing.T, name, content string) {
+	if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+	if err := runTestGit("add", name); err != nil {
+		t.Fatalf("failed to stage %s: %v", name, err)
+	}
+	if err := runTestGit("commit", "-q", "-m", "add "+name); err != nil {
+		t.Fatalf("failed to commit %s: %v", name, err)
+	}
+}
+
+// Tests that dirty working trees are detected and their files reported.
+func TestRequireCleanTree(t *testing.T) {
+	_, cleanup := newTestRepo(t)
+	defer cleanup()
+
+	commitTestFile(t, "README", "hello")
+	if err := RequireCleanTree(); err != nil {
+		t.Fatalf("clean tree rejected: %v", err)
+	}
+	if GitDirty() {
+		t.Errorf("clean tree reported dirty")
+	}
+	if err := ioutil.WriteFile("README", []byte("modified"), 0644); err != nil {
+		t.Fatalf("failed to modify file: %v", err)
+	}
+	err := RequireCleanTree()
+	if err == nil {
+		t.Fatalf("dirty tree accepted")
+	}
+	if !strings.Contains(err.Error(), "README") {
+		t.Errorf("error doesn't name the dirty file: %v", err)
+	}
+	if !GitDirty() {
+		t.Errorf("dirty tree reported clean")
+	}
+}
+
+// Tests that untracked files are reported while ignored ones are excluded.
+func TestUntrackedFiles(t *testing.T) {
+	_, cleanup := newTestRepo(t)
+	defer cleanup()
+
+	commitTestFile(t, ".gitignore", "*.log\n")
+	if files := UntrackedFiles(); len(files) != 0 {
+		t.Fatalf("clean repo reported untracked files: %v", files)
+	}
+	for _, name := range []string{"stray.go", "debug.log"} {
+		if err := ioutil.WriteFile(name, []byte(name), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	if have, want := UntrackedFiles(), []string{"stray.go"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("untracked files mismatch: have %v, want %v", have, want)
+	}
+}
+
+// Tests that release tagging aborts on existing tags and otherwise creates and
+// pushes an annotated tag.
+func TestReleaseTag(t *testing.T) {
+	skipNoShell(t)
+
+	dir, err := ioutil.TempDir("", "build-stub-")
+	if err != nil {
+		t.Fatalf("failed to create stub dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	oldGit := gitBinary
+	defer func() { gitBinary = oldGit }()
+	gitBinary = writeStub(t, dir, "git", `
+case "$1" in
+	status) ;;
+	tag)    [ "$2" = "--list" ] && printf 'v1.5.0\nv1.6.0\n' || echo "$@" >> "$(dirname "$0")/calls" ;;
+	*)      echo "$@" >> "$(dirname "$0")/calls" ;;
+esac
+`)
+	calls := filepath.Join(dir, "calls")
+
+	if err := ReleaseTag("v1.6.0", "Geth v1.6.0", true); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("existing tag error mismatch: have %v, want already exists", err)
+	}
+	if _, err := os.Stat(calls); !os.IsNotExist(err) {
+		t.Fatalf("commands were run despite existing tag")
+	}
+	if err := ReleaseTag("v1.6.1", "Geth v1.6.1", true); err != nil {
+		t.Fatalf("failed to tag release: %v", err)
+	}
+	have, err := ioutil.ReadFile(calls)
+	if err != nil {
+		t.Fatalf("failed to read recorded calls: %v", err)
+	}
+	want := "tag -a v1.6.1 -m Geth v1.6.1\npush origin v1.6.1\n"
+	if string(have) != want {
+		t.Errorf("git invocations mismatch:\nhave %q\nwant %q", have, want)
+	}
+}
+
+// Tests that untracked files within a directory are reported, while other
+// directories and ignored files don't matter.
+func TestRequireTracked(t *testing.T) {
+	_, cleanup := newTestRepo(t)
+	defer cleanup()
+
+	if err := os.MkdirAll("src", 0755); err != nil {
+		t.Fatalf("failed to create src dir: %v", err)
+	}
+	commitTestFile(t, ".gitignore", "*.o\n")
+	commitTestFile(t, "src/main.go", "package main")
+	for _, name := range []string{"src/main.o", "notes.txt"} {
+		if err := ioutil.WriteFile(name, []byte(name), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	if err := RequireTracked("src"); err != nil {
+		t.Errorf("committed dir rejected: %v", err)
+	}
+	if err := ioutil.WriteFile("src/cruft.go", []byte("package main"), 0644); err != nil {
+		t.Fatalf("failed to write cruft: %v", err)
+	}
+	err := RequireTracked("src")
+	if err == nil {
+		t.Fatalf("untracked file accepted")
+	}
+	if !strings.Contains(err.Error(), "src/cruft.go") || strings.Contains(err.Error(), "main.o") {
+		t.Errorf("error mismatch: %v", err)
+	}
+}
+
+// Tests that export-ignored paths are excluded from source exports.
+func TestExportFiles(t *testing.T) {
+	dir, cleanup := newTestRepo(t)
+	defer cleanup()
+
+	if err := os.MkdirAll(filepath.Join("tests", "fixtures"), 0755); err != nil {
+		t.Fatalf("failed to create fixtures dir: %v", err)
+	}
+	commitTestFile(t, ".gitattributes", "/tests export-ignore\n.travis.yml export-ignore\n")
+	commitTestFile(t, ".travis.yml", "language: go")
+	commitTestFile(t, "main.go", "package main")
+	commitTestFile(t, "tests/fixtures/block.json", "{}")
+
+	files, err := ExportFiles("HEAD")
+	if err != nil {
+		t.Fatalf("failed to list export files: %v", err)
+	}
+	if want := []string{".gitattributes", "main.go"}; !reflect.DeepEqual(files, want) {
+		t.Errorf("export files mismatch: have %v, want %v", files, want)
+	}
+	archive := filepath.Join(dir, "geth-src.zip")
+	if err := GitArchive("HEAD", "geth-src/", archive); err != nil {
+		t.Fatalf("failed to archive: %v", err)
+	}
+	if have, want := archiveEntries(t, archive), []string{"geth-src/", "geth-src/.gitattributes", "geth-src/main.go"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("archive entries mismatch: have %v, want %v", have, want)
+	}
+}
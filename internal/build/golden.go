@@ -0,0 +1,66 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CheckGolden compares actual against the content of the golden file. If update
+// is set, the golden file is (re)written with actual instead. On mismatch the
+// returned error describes the differing lines.
+func CheckGolden(goldenPath string, actual []byte, update bool) error {
+	if update {
+		if err := os.MkdirAll(filepath.Dir(goldenPath), 0755); err != nil {
+			return err
+		}
+		return ioutil.WriteFile(goldenPath, actual, 0644)
+	}
+	golden, err := ioutil.ReadFile(goldenPath)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(golden, actual) {
+		return nil
+	}
+	return fmt.Errorf("%s: output mismatch:\n%s", goldenPath, lineDiff(string(golden), string(actual)))
+}
+
+// lineDiff produces a simple line by line difference listing between want and
+// have, marking lines only in want with "-" and lines only in have with "+".
+func lineDiff(want, have string) string {
+	wlines, hlines := strings.Split(want, "\n"), strings.Split(have, "\n")
+
+	var diff bytes.Buffer
+	for i := 0; i < len(wlines) || i < len(hlines); i++ {
+		switch {
+		case i >= len(wlines):
+			fmt.Fprintf(&diff, "%4d + %s\n", i+1, hlines[i])
+		case i >= len(hlines):
+			fmt.Fprintf(&diff, "%4d - %s\n", i+1, wlines[i])
+		case wlines[i] != hlines[i]:
+			fmt.Fprintf(&diff, "%4d - %s\n", i+1, wlines[i])
+			fmt.Fprintf(&diff, "%4d + %s\n", i+1, hlines[i])
+		}
+	}
+	return diff.String()
+}
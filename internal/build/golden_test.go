@@ -0,0 +1,59 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Tests golden file updating, matching and mismatch reporting.
+func TestCheckGolden(t *testing.T) {
+	dir, err := ioutil.TempDir("", "build-golden-")
+	if err != nil {
+		t.Fatalf("failed to create temporary dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	golden := filepath.Join(dir, "testdata", "out.golden")
+	output := []byte("package main\n\nconst version = \"1.6.1\"\n")
+
+	if err := CheckGolden(golden, output, true); err != nil {
+		t.Fatalf("failed to update golden file: %v", err)
+	}
+	if have, _ := ioutil.ReadFile(golden); string(have) != string(output) {
+		t.Errorf("golden file mismatch: have %q, want %q", have, output)
+	}
+	if err := CheckGolden(golden, output, false); err != nil {
+		t.Errorf("matching output rejected: %v", err)
+	}
+	err = CheckGolden(golden, []byte("package main\n\nconst version = \"1.6.2\"\n"), false)
+	if err == nil {
+		t.Fatalf("mismatching output accepted")
+	}
+	for _, want := range []string{`3 - const version = "1.6.1"`, `3 + const version = "1.6.2"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("diff missing %q: %v", want, err)
+		}
+	}
+	if strings.Contains(err.Error(), "package main") {
+		t.Errorf("diff contains unchanged lines: %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// RewriteImportPrefix parses the given Go source, replaces oldPrefix with
+// newPrefix in all import paths equal to or nested below oldPrefix, and returns
+// the reformatted source. Paths merely sharing a string prefix (e.g. "foo/barbaz"
+// for "foo/bar") are left untouched.
+func RewriteImportPrefix(src, oldPrefix, newPrefix string) (string, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return "", err
+	}
+	for _, spec := range file.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			return "", err
+		}
+		if path == oldPrefix || strings.HasPrefix(path, oldPrefix+"/") {
+			spec.Path.Value = strconv.Quote(newPrefix + strings.TrimPrefix(path, oldPrefix))
+		}
+	}
+	ast.SortImports(fset, file)
+
+	var out bytes.Buffer
+	if err := format.Node(&out, fset, file); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
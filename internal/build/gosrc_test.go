@@ -0,0 +1,68 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"go/parser"
+	"go/token"
+	"strconv"
+	"testing"
+)
+
+// Tests that only imports below the old prefix are rewritten.
+func TestRewriteImportPrefix(t *testing.T) {
+	src := `package main
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	eth "github.com/ethereum/go-ethereum/eth/downloader"
+	"github.com/ethereum/go-ethereum-extra/tools"
+)
+
+func main() { fmt.Println(common.Address{}, ethereum.NotFound, eth.ErrBusy, tools.X) }
+`
+	have, err := RewriteImportPrefix(src, "github.com/ethereum/go-ethereum", "example.com/fork")
+	if err != nil {
+		t.Fatalf("failed to rewrite imports: %v", err)
+	}
+	file, err := parser.ParseFile(token.NewFileSet(), "", have, parser.ImportsOnly)
+	if err != nil {
+		t.Fatalf("rewritten source doesn't parse: %v\n%s", err, have)
+	}
+	imports := make(map[string]bool)
+	for _, spec := range file.Imports {
+		path, _ := strconv.Unquote(spec.Path.Value)
+		imports[path] = true
+	}
+	for _, want := range []string{
+		"fmt",
+		"example.com/fork",
+		"example.com/fork/common",
+		"example.com/fork/eth/downloader",
+		"github.com/ethereum/go-ethereum-extra/tools",
+	} {
+		if !imports[want] {
+			t.Errorf("missing import %q in rewritten source:\n%s", want, have)
+		}
+	}
+	if len(imports) != 5 {
+		t.Errorf("import count mismatch: have %d, want %d", len(imports), 5)
+	}
+}
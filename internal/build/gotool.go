@@ -0,0 +1,387 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// goEnv returns the value of a go environment variable as reported by go env.
+func goEnv(key string) (string, error) {
+	out, err := exec.Command(goBinary, "env", key).Output()
+	if err != nil {
+		return "", fmt.Errorf("go env %s failed: %v", key, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// GoCacheDir returns the location of the go build cache.
+func GoCacheDir() (string, error) {
+	return goEnv("GOCACHE")
+}
+
+// GoModCacheDir returns the location of the go module cache.
+func GoModCacheDir() (string, error) {
+	return goEnv("GOMODCACHE")
+}
+
+// goFlags returns the flags set in GOFLAGS as reported by go env.
+func goFlags() ([]string, error) {
+	flags, err := goEnv("GOFLAGS")
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(flags), nil
+}
+
+// EffectiveGoFlags returns the flags the go tool implicitly applies to every
+// build via GOFLAGS (from the environment or go env -w). The result is empty if
+// the go tool can't be queried.
+func EffectiveGoFlags() []string {
+	flags, _ := goFlags()
+	return flags
+}
+
+// RequireNoGoFlag returns an error if GOFLAGS contains the given flag. A flag
+// with a value (e.g. "-mod=mod") must match exactly, while a bare flag name
+// (e.g. "-mod") matches any value.
+func RequireNoGoFlag(flag string) error {
+	flags, err := goFlags()
+	if err != nil {
+		return err
+	}
+	for _, f := range flags {
+		if f == flag || (!strings.Contains(flag, "=") && strings.HasPrefix(f, flag+"=")) {
+			return fmt.Errorf("GOFLAGS contains forbidden flag %s", f)
+		}
+	}
+	return nil
+}
+
+// ClearGoCache removes the entire go build cache.
+func ClearGoCache() error {
+	return runCommand(exec.Command(goBinary, "clean", "-cache"))
+}
+
+// ClearGoModCache removes the entire go module cache.
+func ClearGoModCache() error {
+	return runCommand(exec.Command(goBinary, "clean", "-modcache"))
+}
+
+// RunGoGenerate expands the given package patterns (skipping vendored packages)
+// and runs go generate on each of them. If run is non-empty, it is passed as the
+// -run regexp selecting which directives to execute. The failures of all
+// packages are aggregated into the returned error.
+func RunGoGenerate(patterns []string, run string) error {
+	var failures []string
+	for _, pkg := range ExpandPackagesNoVendor(patterns) {
+		if pkg == "" {
+			continue
+		}
+		args := []string{"generate"}
+		if run != "" {
+			args = append(args, "-run", run)
+		}
+		args = append(args, pkg)
+		if err := runCommand(exec.Command(goBinary, args...)); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", pkg, err))
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("go generate failed:\n  %s", strings.Join(failures, "\n  "))
+	}
+	return nil
+}
+
+// WithCompilerCache returns a copy of the given environment overrides where the
+// C and C++ compilers used by cgo are wrapped in ccache (or sccache) if either
+// is installed. Compilers not set in env default to the ones from the process
+// environment, or cc and c++. The result is suitable as the extra environment
+// of MustRunCleanEnv.
+func WithCompilerCache(env map[string]string) map[string]string {
+	result := make(map[string]string, len(env)+2)
+	for key, value := range env {
+		result[key] = value
+	}
+	var cache string
+	for _, tool := range []string{"ccache", "sccache"} {
+		if path, err := exec.LookPath(tool); err == nil {
+			cache = path
+			break
+		}
+	}
+	if cache == "" {
+		return result
+	}
+	for key, def := range map[string]string{"CC": "cc", "CXX": "c++"} {
+		compiler, ok := result[key]
+		if !ok {
+			if compiler = os.Getenv(key); compiler == "" {
+				compiler = def
+			}
+		}
+		if fields := strings.Fields(compiler); len(fields) > 0 && filepath.Base(fields[0]) == filepath.Base(cache) {
+			continue // already cached
+		}
+		result[key] = cache + " " + compiler
+	}
+	return result
+}
+
+// TestFiles returns the absolute paths of all test files (both in-package and
+// external _test packages) of the given package.
+func TestFiles(pkgPath string) ([]string, error) {
+	format := "{{.Dir}}\n{{range .TestGoFiles}}{{.}} {{end}}{{range .XTestGoFiles}}{{.}} {{end}}"
+	out, err := exec.Command(goBinary, "list", "-f", format, pkgPath).Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list %s failed: %v", pkgPath, err)
+	}
+	lines := strings.SplitN(string(out), "\n", 2)
+	if len(lines) != 2 {
+		return nil, fmt.Errorf("unexpected go list output: %q", out)
+	}
+	var files []string
+	for _, name := range strings.Fields(lines[1]) {
+		files = append(files, filepath.Join(lines[0], name))
+	}
+	return files, nil
+}
+
+// TransitiveDeps returns the sorted import paths of all packages compiled into
+// the given main package, including itself. If excludeStd is set, standard
+// library packages are omitted.
+func TransitiveDeps(mainPkg string, excludeStd bool) ([]string, error) {
+	out, err := exec.Command(goBinary, "list", "-deps", "-f", "{{.ImportPath}} {{.Standard}}", mainPkg).Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list -deps %s failed: %v", mainPkg, err)
+	}
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || (excludeStd && fields[1] == "true") {
+			continue
+		}
+		seen[fields[0]] = true
+	}
+	deps := make([]string, 0, len(seen))
+	for dep := range seen {
+		deps = append(deps, dep)
+	}
+	sort.Strings(deps)
+	return deps, nil
+}
+
+// VetPackage runs go vet on the given package, returning the diagnostics vet
+// reported as part of the error if it fails.
+func VetPackage(pkgPath string) error {
+	cmd := exec.Command(goBinary, "vet", pkgPath)
+	fmt.Println(">>>", strings.Join(cmd.Args, " "))
+	if *DryRunFlag {
+		return nil
+	}
+	var stderr bytes.Buffer
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = &stderr
+	if err := runAbortable(cmd); err != nil {
+		return fmt.Errorf("go vet %s failed: %v\n%s", pkgPath, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// RunWithCoverage runs the tests of the given packages with atomic coverage
+// accounting, writing the coverage profile to profilePath. An error is returned
+// if the tests fail or no profile was produced.
+func RunWithCoverage(pkgs []string, profilePath string) error {
+	args := []string{"test", "-coverprofile=" + profilePath, "-covermode=atomic"}
+	for _, pkg := range ExpandPackagesNoVendor(pkgs) {
+		if pkg != "" {
+			args = append(args, pkg)
+		}
+	}
+	if err := runCommand(exec.Command(goBinary, args...)); err != nil {
+		return fmt.Errorf("go test failed: %v", err)
+	}
+	if *DryRunFlag {
+		return nil
+	}
+	if _, err := os.Stat(profilePath); err != nil {
+		return fmt.Errorf("coverage profile not produced: %v", err)
+	}
+	return nil
+}
+
+// GeneratedDrift runs go generate on the given package patterns and returns the
+// files in the directories of those packages which were changed, created or
+// deleted by it, relative to the working directory. A non-empty result means
+// the committed generated files are stale.
+func GeneratedDrift(patterns []string) ([]string, error) {
+	dirs, err := packageDirs(patterns)
+	if err != nil {
+		return nil, err
+	}
+	before, err := hashDirs(dirs)
+	if err != nil {
+		return nil, err
+	}
+	if err := RunGoGenerate(patterns, ""); err != nil {
+		return nil, err
+	}
+	after, err := hashDirs(dirs)
+	if err != nil {
+		return nil, err
+	}
+	var drifted []string
+	for path, hash := range after {
+		if before[path] != hash {
+			drifted = append(drifted, path)
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			drifted = append(drifted, path)
+		}
+	}
+	sort.Strings(drifted)
+	return drifted, nil
+}
+
+// packageDirs returns the source directories of the packages matched by the
+// given patterns, skipping vendored packages.
+func packageDirs(patterns []string) ([]string, error) {
+	args := []string{"list", "-f", "{{.Dir}}"}
+	for _, pkg := range ExpandPackagesNoVendor(patterns) {
+		if pkg != "" {
+			args = append(args, pkg)
+		}
+	}
+	out, err := exec.Command(goBinary, args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list failed: %v", err)
+	}
+	var dirs []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if dir := strings.TrimSpace(line); dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs, nil
+}
+
+// hashDirs returns the SHA-256 digests of the regular files directly within the
+// given directories, keyed by their slash separated path relative to the working
+// directory.
+func hashDirs(dirs []string) (map[string]string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	hashes := make(map[string]string)
+	for _, dir := range dirs {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if !file.Mode().IsRegular() {
+				continue
+			}
+			path := filepath.Join(dir, file.Name())
+			hash, err := fileSHA256(path)
+			if err != nil {
+				return nil, err
+			}
+			if rel, err := filepath.Rel(cwd, path); err == nil {
+				path = rel
+			}
+			hashes[filepath.ToSlash(path)] = hash
+		}
+	}
+	return hashes, nil
+}
+
+// Module describes a module in the build list as reported by go list -m.
+type Module struct {
+	Path    string
+	Version string
+	Dir     string // Source directory, empty if not present locally
+	Main    bool
+	Replace *Module
+}
+
+// ModuleDeps returns the modules required by the main module, excluding the
+// main module itself. Replaced modules report the replacement's directory.
+func ModuleDeps() ([]Module, error) {
+	out, err := exec.Command(goBinary, "list", "-m", "-json", "all").Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list -m all failed: %v", err)
+	}
+	var deps []Module
+	for dec := json.NewDecoder(bytes.NewReader(out)); ; {
+		var mod Module
+		if err := dec.Decode(&mod); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("can't decode go list output: %v", err)
+		}
+		if !mod.Main {
+			deps = append(deps, mod)
+		}
+	}
+	return deps, nil
+}
+
+// goVersion returns the major and minor release version of the go tool, e.g.
+// 1 and 8 for go1.8.1. Development builds are reported as arbitrarily new.
+func goVersion() (int, int, error) {
+	out, err := exec.Command(goBinary, "version").Output()
+	if err != nil {
+		return 0, 0, fmt.Errorf("go version failed: %v", err)
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) < 3 {
+		return 0, 0, fmt.Errorf("unexpected go version output: %q", out)
+	}
+	if strings.HasPrefix(fields[2], "devel") {
+		return math.MaxInt32, 0, nil
+	}
+	parts := strings.SplitN(strings.TrimPrefix(fields[2], "go"), ".", 3)
+	major, err := strconv.Atoi(parts[0])
+	if err != nil || len(parts) < 2 {
+		return 0, 0, fmt.Errorf("unexpected go version %q", fields[2])
+	}
+	// Pre-releases carry their suffix on the minor version (e.g. go1.9beta2)
+	digits := strings.IndexFunc(parts[1], func(r rune) bool { return r < '0' || r > '9' })
+	if digits < 0 {
+		digits = len(parts[1])
+	}
+	minor, err := strconv.Atoi(parts[1][:digits])
+	if err != nil {
+		return 0, 0, fmt.Errorf("unexpected go version %q", fields[2])
+	}
+	return major, minor, nil
+}
@@ -0,0 +1,349 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Tests that go generate is invoked with the -run filter on every expanded,
+// non-vendored package.
+func TestRunGoGenerate(t *testing.T) {
+	dir, cleanup := stubGo(t, `
+if [ "$1" = "list" ]; then
+	printf 'example.com/a\nexample.com/b\nexample.com/vendor/c\n'
+	exit 0
+fi
+echo "$@" >> "$(dirname "$0")/calls"
+`)
+	defer cleanup()
+
+	if err := RunGoGenerate([]string{"./..."}, "stringer"); err != nil {
+		t.Fatalf("failed to run go generate: %v", err)
+	}
+	calls, err := ioutil.ReadFile(filepath.Join(dir, "calls"))
+	if err != nil {
+		t.Fatalf("failed to read recorded calls: %v", err)
+	}
+	want := "generate -run stringer example.com/a\ngenerate -run stringer example.com/b\n"
+	if string(calls) != want {
+		t.Errorf("go generate invocations mismatch:\nhave %q\nwant %q", calls, want)
+	}
+	if strings.Contains(string(calls), "vendor") {
+		t.Errorf("vendored package was generated")
+	}
+}
+
+// Tests that the cache locations are queried from go env and that clearing
+// them invokes go clean.
+func TestGoCache(t *testing.T) {
+	dir, cleanup := stubGo(t, `
+case "$1 $2" in
+	"env GOCACHE")    echo /tmp/gocache ;;
+	"env GOMODCACHE") echo /tmp/gomodcache ;;
+	*)                echo "$@" >> "$(dirname "$0")/calls" ;;
+esac
+`)
+	defer cleanup()
+
+	if have, err := GoCacheDir(); err != nil || have != "/tmp/gocache" {
+		t.Errorf("build cache mismatch: have %q, %v, want %q", have, err, "/tmp/gocache")
+	}
+	if have, err := GoModCacheDir(); err != nil || have != "/tmp/gomodcache" {
+		t.Errorf("module cache mismatch: have %q, %v, want %q", have, err, "/tmp/gomodcache")
+	}
+	if err := ClearGoCache(); err != nil {
+		t.Fatalf("failed to clear build cache: %v", err)
+	}
+	if err := ClearGoModCache(); err != nil {
+		t.Fatalf("failed to clear module cache: %v", err)
+	}
+	calls, err := ioutil.ReadFile(filepath.Join(dir, "calls"))
+	if err != nil {
+		t.Fatalf("failed to read recorded calls: %v", err)
+	}
+	if want := "clean -cache\nclean -modcache\n"; string(calls) != want {
+		t.Errorf("go clean invocations mismatch: have %q, want %q", calls, want)
+	}
+}
+
+// Tests that compilers are wrapped in ccache only if it is installed.
+func TestWithCompilerCache(t *testing.T) {
+	skipNoShell(t)
+
+	dir, err := ioutil.TempDir("", "build-ccache-")
+	if err != nil {
+		t.Fatalf("failed to create temporary dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	defer os.Setenv("CXX", os.Getenv("CXX"))
+	os.Setenv("PATH", dir)
+	os.Unsetenv("CXX")
+
+	env := map[string]string{"CC": "arm-linux-gnueabi-gcc", "GOARM": "7"}
+	if have := WithCompilerCache(env); have["CC"] != env["CC"] || have["CXX"] != "" {
+		t.Errorf("compilers rewritten without ccache: %v", have)
+	}
+	ccache := writeStub(t, dir, "ccache", "exec \"$@\"")
+	have := WithCompilerCache(env)
+	if want := ccache + " arm-linux-gnueabi-gcc"; have["CC"] != want {
+		t.Errorf("CC mismatch: have %q, want %q", have["CC"], want)
+	}
+	if want := ccache + " c++"; have["CXX"] != want {
+		t.Errorf("CXX mismatch: have %q, want %q", have["CXX"], want)
+	}
+	if have["GOARM"] != "7" || env["CC"] != "arm-linux-gnueabi-gcc" {
+		t.Errorf("unrelated or input values modified: have %v, input %v", have, env)
+	}
+	if again := WithCompilerCache(have); again["CC"] != have["CC"] {
+		t.Errorf("cached compiler wrapped twice: %q", again["CC"])
+	}
+}
+
+// newTestModule creates a Go module in a temporary directory with the given
+// files, and switches the working directory into it with module mode enabled.
+// The returned function restores the previous state and removes the module.
+func newTestModule(t *testing.T, files map[string]string) (string, func()) {
+	if _, err := exec.LookPath(goBinary); err != nil {
+		t.Skip("go tool not available")
+	}
+	dir, err := ioutil.TempDir("", "build-module-")
+	if err != nil {
+		t.Fatalf("failed to create module dir: %v", err)
+	}
+	// Resolve symlinks so paths reported by the go tool match
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		t.Fatalf("failed to resolve module dir: %v", err)
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create dir for %s: %v", name, err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get working directory: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("failed to enter module dir: %v", err)
+	}
+	env := map[string]string{"GO111MODULE": "on", "GOFLAGS": "-mod=mod", "GOPROXY": "off"}
+	old := make(map[string]string)
+	for key, value := range env {
+		old[key] = os.Getenv(key)
+		os.Setenv(key, value)
+	}
+	return dir, func() {
+		for key, value := range old {
+			os.Setenv(key, value)
+		}
+		os.Chdir(cwd)
+		os.RemoveAll(dir)
+	}
+}
+
+// Tests that both internal and external test files of a package are listed.
+func TestTestFiles(t *testing.T) {
+	dir, cleanup := newTestModule(t, map[string]string{
+		"go.mod":              "module example.com/m\n",
+		"pkg/pkg.go":          "package pkg\n",
+		"pkg/pkg_test.go":     "package pkg\n",
+		"pkg/export_test.go":  "package pkg_test\n",
+		"other/other_test.go": "package other\n",
+	})
+	defer cleanup()
+
+	files, err := TestFiles("./pkg")
+	if err != nil {
+		t.Fatalf("failed to list test files: %v", err)
+	}
+	want := []string{filepath.Join(dir, "pkg", "pkg_test.go"), filepath.Join(dir, "pkg", "export_test.go")}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("test files mismatch: have %v, want %v", files, want)
+	}
+}
+
+// Tests that the transitive dependencies of a binary are listed, optionally
+// without the standard library.
+func TestTransitiveDeps(t *testing.T) {
+	_, cleanup := newTestModule(t, map[string]string{
+		"go.mod":         "module example.com/m\n",
+		"cmd/app/app.go": "package main\n\nimport \"example.com/m/lib\"\n\nfunc main() { lib.Hello() }\n",
+		"lib/lib.go":     "package lib\n\nimport \"fmt\"\n\nfunc Hello() { fmt.Println(\"hello\") }\n",
+	})
+	defer cleanup()
+
+	deps, err := TransitiveDeps("./cmd/app", false)
+	if err != nil {
+		t.Fatalf("failed to list dependencies: %v", err)
+	}
+	found := make(map[string]bool)
+	for _, dep := range deps {
+		found[dep] = true
+	}
+	for _, want := range []string{"example.com/m/cmd/app", "example.com/m/lib", "fmt"} {
+		if !found[want] {
+			t.Errorf("dependency %s missing from %v", want, deps)
+		}
+	}
+	deps, err = TransitiveDeps("./cmd/app", true)
+	if err != nil {
+		t.Fatalf("failed to list dependencies: %v", err)
+	}
+	if want := []string{"example.com/m/cmd/app", "example.com/m/lib"}; !reflect.DeepEqual(deps, want) {
+		t.Errorf("non-stdlib dependencies mismatch: have %v, want %v", deps, want)
+	}
+}
+
+// Tests that vet diagnostics are surfaced through the returned error.
+func TestVetPackage(t *testing.T) {
+	_, cleanup := stubGo(t, `
+if [ "$2" = "./good" ]; then exit 0; fi
+echo "gen.go:3:2: unreachable code" >&2
+exit 1
+`)
+	defer cleanup()
+
+	if err := VetPackage("./good"); err != nil {
+		t.Errorf("clean package rejected: %v", err)
+	}
+	err := VetPackage("./bad")
+	if err == nil {
+		t.Fatalf("vet failure not reported")
+	}
+	if !strings.Contains(err.Error(), "gen.go:3:2: unreachable code") {
+		t.Errorf("error missing vet diagnostics: %v", err)
+	}
+}
+
+// Tests that coverage runs request an atomic profile for the expanded packages
+// and that a missing profile is reported.
+func TestRunWithCoverage(t *testing.T) {
+	dir, cleanup := stubGo(t, `
+if [ "$1" = "list" ]; then
+	printf 'example.com/a\nexample.com/vendor/b\n'
+	exit 0
+fi
+echo "$@" > "$(dirname "$0")/calls"
+case "$2" in
+	-coverprofile=*) [ -n "$NO_PROFILE" ] || echo "mode: atomic" > "${2#-coverprofile=}" ;;
+esac
+`)
+	defer cleanup()
+
+	profile := filepath.Join(dir, "coverage.out")
+	if err := RunWithCoverage([]string{"./..."}, profile); err != nil {
+		t.Fatalf("failed to run coverage: %v", err)
+	}
+	calls, err := ioutil.ReadFile(filepath.Join(dir, "calls"))
+	if err != nil {
+		t.Fatalf("failed to read recorded calls: %v", err)
+	}
+	want := "test -coverprofile=" + profile + " -covermode=atomic example.com/a\n"
+	if string(calls) != want {
+		t.Errorf("go test invocation mismatch:\nhave %q\nwant %q", calls, want)
+	}
+	// Remove the profile and make the stub not produce one
+	os.Remove(profile)
+	os.Setenv("NO_PROFILE", "1")
+	defer os.Unsetenv("NO_PROFILE")
+
+	if err := RunWithCoverage([]string{"example.com/a"}, profile); err == nil {
+		t.Errorf("missing coverage profile not reported")
+	}
+}
+
+// Tests that GOFLAGS is split into individual flags and that forbidden flags
+// are detected in it.
+func TestRequireNoGoFlag(t *testing.T) {
+	_, cleanup := stubGo(t, `[ "$1 $2" = "env GOFLAGS" ] && echo "-mod=mod  -trimpath"`)
+	defer cleanup()
+
+	if have, want := EffectiveGoFlags(), []string{"-mod=mod", "-trimpath"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("flags mismatch: have %v, want %v", have, want)
+	}
+	tests := []struct {
+		flag string
+		fail bool
+	}{
+		{"-mod=mod", true},
+		{"-mod", true},
+		{"-trimpath", true},
+		{"-mod=vendor", false},
+		{"-race", false},
+	}
+	for i, tt := range tests {
+		err := RequireNoGoFlag(tt.flag)
+		switch {
+		case tt.fail && err == nil:
+			t.Errorf("test %d: forbidden flag %s not detected", i, tt.flag)
+		case !tt.fail && err != nil:
+			t.Errorf("test %d: absent flag %s reported: %v", i, tt.flag, err)
+		}
+	}
+}
+
+// Tests that files modified, created or deleted by go generate within the checked
+// packages are reported as drifted, while changes elsewhere are ignored.
+func TestGeneratedDrift(t *testing.T) {
+	_, cleanup := newTestRepo(t)
+	defer cleanup()
+
+	for _, dir := range []string{"a", "b"} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatalf("failed to create package dir: %v", err)
+		}
+	}
+	commitTestFile(t, "a/gen_up2date.go", "package a\n")
+	commitTestFile(t, "a/gen_stale.go", "package a // old\n")
+	commitTestFile(t, "a/gen_removed.go", "package a\n")
+	commitTestFile(t, "a/main.go", "package a\n")
+	commitTestFile(t, "b/gen_other.go", "package b // old\n")
+
+	// The generator also touches a package outside the checked patterns
+	_, unstub := stubGo(t, `
+case "$1" in
+	list)     echo "$PWD/a" ;;
+	generate) echo "package a" > a/gen_up2date.go
+	          echo "package a // new" > a/gen_stale.go
+	          echo "package a" > a/gen_added.go
+	          rm a/gen_removed.go
+	          echo "package b // new" > b/gen_other.go ;;
+esac
+`)
+	defer unstub()
+
+	drifted, err := GeneratedDrift([]string{"./a"})
+	if err != nil {
+		t.Fatalf("failed to check generated drift: %v", err)
+	}
+	if want := []string{"a/gen_added.go", "a/gen_removed.go", "a/gen_stale.go"}; !reflect.DeepEqual(drifted, want) {
+		t.Errorf("drifted files mismatch: have %v, want %v", drifted, want)
+	}
+}
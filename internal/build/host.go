@@ -0,0 +1,68 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// lddBinary is the dynamic linker inspection tool used to detect the host libc.
+var lddBinary = "ldd"
+
+// HostLibc returns the C library flavour of the host, "glibc" or "musl", as
+// reported by ldd. It returns "unknown" if detection failed or if the host is
+// not running Linux.
+func HostLibc() string {
+	if runtime.GOOS != "linux" {
+		return "unknown"
+	}
+	// musl's ldd prints its banner to stderr and exits with an error, so the
+	// exit status is deliberately ignored.
+	out, _ := exec.Command(lddBinary, "--version").CombinedOutput()
+
+	banner := strings.ToLower(string(out))
+	switch {
+	case strings.Contains(banner, "musl"):
+		return "musl"
+	case strings.Contains(banner, "glibc"), strings.Contains(banner, "gnu libc"):
+		return "glibc"
+	default:
+		return "unknown"
+	}
+}
+
+// toolVersionPattern matches the first version-like token of a version banner.
+var toolVersionPattern = regexp.MustCompile(`[0-9]+\.[0-9]+(\.[0-9]+)?`)
+
+// ToolVersion runs the named tool with the given arguments (e.g. "--version")
+// and returns the first version number found in its output, like "1.2.3" from
+// "foo version 1.2.3 (build x)".
+func ToolVersion(name string, args ...string) (string, error) {
+	out, err := exec.Command(name, args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("%s %s failed: %v", name, strings.Join(args, " "), err)
+	}
+	version := toolVersionPattern.FindString(string(out))
+	if version == "" {
+		return "", fmt.Errorf("no version in %s output: %q", name, strings.TrimSpace(string(out)))
+	}
+	return version, nil
+}
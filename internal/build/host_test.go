@@ -0,0 +1,81 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"io/ioutil"
+	"os"
+	"runtime"
+	"testing"
+)
+
+// Tests that the host libc is classified from the ldd banner.
+func TestHostLibc(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("libc detection is Linux only")
+	}
+	dir, err := ioutil.TempDir("", "build-ldd-")
+	if err != nil {
+		t.Fatalf("failed to create stub dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(ldd string) { lddBinary = ldd }(lddBinary)
+
+	tests := []struct {
+		script string
+		want   string
+	}{
+		{"echo 'ldd (Ubuntu GLIBC 2.23-0ubuntu9) 2.23'", "glibc"},
+		{"echo 'ldd (GNU libc) 2.17'", "glibc"},
+		{"echo 'musl libc (x86_64)' >&2; exit 1", "musl"},
+		{"echo 'something else'", "unknown"},
+	}
+	for i, tt := range tests {
+		lddBinary = writeStub(t, dir, "ldd", tt.script)
+		if have := HostLibc(); have != tt.want {
+			t.Errorf("test %d: libc mismatch: have %s, want %s", i, have, tt.want)
+		}
+	}
+	lddBinary = "/nonexistent/ldd"
+	if have := HostLibc(); have != "unknown" {
+		t.Errorf("missing ldd: libc mismatch: have %s, want unknown", have)
+	}
+}
+
+// Tests that the version number is extracted from a tool's version banner.
+func TestToolVersion(t *testing.T) {
+	skipNoShell(t)
+
+	dir, err := ioutil.TempDir("", "build-tool-")
+	if err != nil {
+		t.Fatalf("failed to create stub dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tool := writeStub(t, dir, "foo", `[ "$1" = "--version" ] && echo "foo version 1.2.3 (build x)"`)
+	if have, err := ToolVersion(tool, "--version"); err != nil || have != "1.2.3" {
+		t.Errorf("version mismatch: have %q, %v, want %q", have, err, "1.2.3")
+	}
+	if _, err := ToolVersion(tool, "version"); err == nil {
+		t.Errorf("failing tool reported a version")
+	}
+	tool = writeStub(t, dir, "bar", `echo "bar development build"`)
+	if _, err := ToolVersion(tool); err == nil {
+		t.Errorf("version found in banner without one")
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+)
+
+// unsupportedSchemaKeywords are the JSON schema validation keywords which the
+// validator doesn't implement. Schemas using them are rejected, as ignoring them
+// would silently accept documents the schema forbids.
+var unsupportedSchemaKeywords = []string{
+	"$ref", "$dynamicRef", "$recursiveRef", "allOf", "anyOf", "oneOf", "not",
+	"if", "then", "else", "exclusiveMinimum", "exclusiveMaximum", "multipleOf",
+	"uniqueItems", "contains", "minContains", "maxContains", "additionalItems",
+	"prefixItems", "unevaluatedItems", "unevaluatedProperties", "minProperties",
+	"maxProperties", "patternProperties", "propertyNames", "dependencies",
+	"dependentRequired", "dependentSchemas", "format", "contentEncoding",
+	"contentMediaType", "contentSchema",
+}
+
+// validateJSONSchema checks the decoded JSON value against the given decoded
+// JSON schema, returning a description of every violation found. Only the
+// commonly used validation keywords are supported: type, enum, const,
+// properties, required, additionalProperties, items, minItems, maxItems,
+// minLength, maxLength, pattern, minimum and maximum. An error is returned if
+// the schema uses any other validation keyword; annotations (e.g. title or
+// description) are ignored.
+func validateJSONSchema(schema map[string]interface{}, value interface{}) ([]string, error) {
+	if err := checkSchemaKeywords(schema, ""); err != nil {
+		return nil, err
+	}
+	var errs []string
+	validateSchemaValue(schema, value, "", &errs)
+	return errs, nil
+}
+
+// checkSchemaKeywords ensures that the schema located at the JSON pointer path,
+// along with all its subschemas, only uses supported validation keywords.
+func checkSchemaKeywords(schema map[string]interface{}, path string) error {
+	for _, keyword := range unsupportedSchemaKeywords {
+		if _, ok := schema[keyword]; ok {
+			return fmt.Errorf("unsupported schema keyword %q at %s/", keyword, path)
+		}
+	}
+	if items, ok := schema["items"]; ok {
+		sub, ok := items.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("unsupported non-object schema keyword \"items\" at %s/", path)
+		}
+		if err := checkSchemaKeywords(sub, path+"/items"); err != nil {
+			return err
+		}
+	}
+	if sub, ok := schema["additionalProperties"].(map[string]interface{}); ok {
+		if err := checkSchemaKeywords(sub, path+"/additionalProperties"); err != nil {
+			return err
+		}
+	}
+	properties, _ := schema["properties"].(map[string]interface{})
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if sub, ok := properties[name].(map[string]interface{}); ok {
+			if err := checkSchemaKeywords(sub, path+"/properties/"+name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateSchemaValue validates value located at the JSON pointer path, appending
+// any violations to errs.
+func validateSchemaValue(schema map[string]interface{}, value interface{}, path string, errs *[]string) {
+	fail := func(format string, args ...interface{}) {
+		location := path
+		if location == "" {
+			location = "/"
+		}
+		*errs = append(*errs, location+": "+fmt.Sprintf(format, args...))
+	}
+	if types, ok := schema["type"]; ok && !matchesSchemaType(types, value) {
+		fail("invalid type %s, want %v", jsonTypeName(value), types)
+		return
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, option := range enum {
+			if reflect.DeepEqual(option, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("value %v not in enum %v", value, enum)
+		}
+	}
+	if want, ok := schema["const"]; ok && !reflect.DeepEqual(want, value) {
+		fail("value %v doesn't match const %v", value, want)
+	}
+	switch value := value.(type) {
+	case map[string]interface{}:
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, name := range required {
+				if name, ok := name.(string); ok {
+					if _, ok := value[name]; !ok {
+						fail("missing required property %q", name)
+					}
+				}
+			}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		names := make([]string, 0, len(value))
+		for name := range value {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if sub, ok := properties[name].(map[string]interface{}); ok {
+				validateSchemaValue(sub, value[name], path+"/"+name, errs)
+				continue
+			}
+			switch extra := schema["additionalProperties"].(type) {
+			case bool:
+				if !extra {
+					fail("unexpected property %q", name)
+				}
+			case map[string]interface{}:
+				validateSchemaValue(extra, value[name], path+"/"+name, errs)
+			}
+		}
+	case []interface{}:
+		if min, ok := schema["minItems"].(float64); ok && float64(len(value)) < min {
+			fail("too few items: have %d, want at least %v", len(value), min)
+		}
+		if max, ok := schema["maxItems"].(float64); ok && float64(len(value)) > max {
+			fail("too many items: have %d, want at most %v", len(value), max)
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range value {
+				validateSchemaValue(items, item, fmt.Sprintf("%s/%d", path, i), errs)
+			}
+		}
+	case string:
+		length := len([]rune(value))
+		if min, ok := schema["minLength"].(float64); ok && float64(length) < min {
+			fail("string too short: have %d characters, want at least %v", length, min)
+		}
+		if max, ok := schema["maxLength"].(float64); ok && float64(length) > max {
+			fail("string too long: have %d characters, want at most %v", length, max)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				fail("invalid schema pattern %q: %v", pattern, err)
+			} else if !re.MatchString(value) {
+				fail("value %q doesn't match pattern %q", value, pattern)
+			}
+		}
+	case float64:
+		if min, ok := schema["minimum"].(float64); ok && value < min {
+			fail("value %v below minimum %v", value, min)
+		}
+		if max, ok := schema["maximum"].(float64); ok && value > max {
+			fail("value %v above maximum %v", value, max)
+		}
+	}
+}
+
+// matchesSchemaType reports whether value is of the schema type, which is either
+// a single type name or a list of them.
+func matchesSchemaType(types interface{}, value interface{}) bool {
+	var names []interface{}
+	switch types := types.(type) {
+	case string:
+		names = []interface{}{types}
+	case []interface{}:
+		names = types
+	}
+	have := jsonTypeName(value)
+	for _, name := range names {
+		if name == have || (name == "number" && have == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonTypeName returns the JSON schema type name of a decoded JSON value.
+func jsonTypeName(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if value == math.Trunc(value) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
@@ -0,0 +1,105 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// Tests that JSON values are validated against the supported schema keywords.
+func TestValidateJSONSchema(t *testing.T) {
+	tests := []struct {
+		schema string
+		value  string
+		errs   []string
+	}{
+		{`{"type": "integer"}`, `1`, nil},
+		{`{"type": "integer"}`, `1.5`, []string{"/: invalid type number, want integer"}},
+		{`{"type": ["string", "null"]}`, `null`, nil},
+		{`{"type": "number", "maximum": 10}`, `11`, []string{"/: value 11 above maximum 10"}},
+		{`{"type": "string", "pattern": "^v[0-9]+$", "maxLength": 3}`, `"v1234"`, []string{"/: string too long: have 5 characters, want at most 3"}},
+		{`{"pattern": "^v[0-9]+$"}`, `"1.0"`, []string{`/: value "1.0" doesn't match pattern "^v[0-9]+$"`}},
+		{`{"const": true}`, `false`, []string{"/: value false doesn't match const true"}},
+		{
+			`{"type": "array", "minItems": 1, "items": {"type": "string"}}`,
+			`["enode", 7]`,
+			[]string{"/1: invalid type integer, want string"},
+		},
+		{`{"type": "array", "minItems": 1}`, `[]`, []string{"/: too few items: have 0, want at least 1"}},
+		{
+			`{"required": ["name"], "properties": {"port": {"type": "integer"}}, "additionalProperties": false}`,
+			`{"port": "30303", "extra": 1}`,
+			[]string{`/: missing required property "name"`, `/: unexpected property "extra"`, "/port: invalid type string, want integer"},
+		},
+		{
+			`{"additionalProperties": {"type": "object", "required": ["enode"]}}`,
+			`{"bootnode": {}}`,
+			[]string{`/bootnode: missing required property "enode"`},
+		},
+	}
+	for i, tt := range tests {
+		var schema map[string]interface{}
+		if err := json.Unmarshal([]byte(tt.schema), &schema); err != nil {
+			t.Fatalf("test %d: invalid schema: %v", i, err)
+		}
+		var value interface{}
+		if err := json.Unmarshal([]byte(tt.value), &value); err != nil {
+			t.Fatalf("test %d: invalid value: %v", i, err)
+		}
+		errs, err := validateJSONSchema(schema, value)
+		if err != nil {
+			t.Fatalf("test %d: schema rejected: %v", i, err)
+		}
+		if !reflect.DeepEqual(errs, tt.errs) {
+			t.Errorf("test %d: errors mismatch:\nhave %q\nwant %q", i, errs, tt.errs)
+		}
+	}
+}
+
+// Tests that schemas using unimplemented validation keywords are rejected instead
+// of silently accepting any document.
+func TestValidateJSONSchemaUnsupported(t *testing.T) {
+	tests := []struct {
+		schema string
+		err    string
+	}{
+		{`{"$ref": "#/definitions/node"}`, `unsupported schema keyword "$ref" at /`},
+		{`{"anyOf": [{"type": "string"}, {"type": "null"}]}`, `unsupported schema keyword "anyOf" at /`},
+		{`{"properties": {"port": {"type": "integer", "exclusiveMinimum": 0}}}`, `unsupported schema keyword "exclusiveMinimum" at /properties/port/`},
+		{`{"items": {"format": "uri"}}`, `unsupported schema keyword "format" at /items/`},
+		{`{"items": [{"type": "string"}]}`, `unsupported non-object schema keyword "items" at /`},
+		{`{"additionalProperties": {"uniqueItems": true}}`, `unsupported schema keyword "uniqueItems" at /additionalProperties/`},
+		{`{"patternProperties": {"^x-": {}}}`, `unsupported schema keyword "patternProperties" at /`},
+	}
+	for i, tt := range tests {
+		var schema map[string]interface{}
+		if err := json.Unmarshal([]byte(tt.schema), &schema); err != nil {
+			t.Fatalf("test %d: invalid schema: %v", i, err)
+		}
+		if _, err := validateJSONSchema(schema, "anything"); err == nil || err.Error() != tt.err {
+			t.Errorf("test %d: error mismatch: have %v, want %s", i, err, tt.err)
+		}
+	}
+	// Annotations don't affect validation and are accepted
+	var schema map[string]interface{}
+	json.Unmarshal([]byte(`{"$schema": "http://json-schema.org/draft-07/schema#", "title": "Genesis", "description": "Chain config", "type": "string"}`), &schema)
+	if errs, err := validateJSONSchema(schema, "anything"); err != nil || len(errs) != 0 {
+		t.Errorf("annotated schema mismatch: errors %v, err %v", errs, err)
+	}
+}
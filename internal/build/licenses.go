@@ -0,0 +1,122 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// unknownLicense is reported for dependencies whose license can't be determined.
+const unknownLicense = "NOASSERTION"
+
+// licenseFiles are the base names (lowercased, without extension) which hold
+// the license text of a module.
+var licenseFiles = map[string]bool{"license": true, "licence": true, "copying": true, "unlicense": true}
+
+// licenseMatchers identifies SPDX licenses by phrases of their text. They are
+// checked in order, so more specific licenses must precede the ones whose text
+// they contain.
+var licenseMatchers = []struct {
+	id      string
+	phrases []string
+}{
+	{"AGPL-3.0", []string{"gnu affero general public license", "version 3"}},
+	{"LGPL-3.0", []string{"gnu lesser general public license", "version 3"}},
+	{"LGPL-2.1", []string{"gnu lesser general public license", "version 2.1"}},
+	{"GPL-3.0", []string{"gnu general public license", "version 3"}},
+	{"GPL-2.0", []string{"gnu general public license", "version 2"}},
+	{"Apache-2.0", []string{"apache license", "version 2.0"}},
+	{"MPL-2.0", []string{"mozilla public license", "2.0"}},
+	{"BSD-3-Clause", []string{"redistribution and use in source and binary forms", "neither the name"}},
+	{"BSD-2-Clause", []string{"redistribution and use in source and binary forms"}},
+	{"MIT", []string{"permission is hereby granted, free of charge"}},
+	{"ISC", []string{"permission to use, copy, modify, and/or distribute this software for any purpose"}},
+	{"Unlicense", []string{"this is free and unencumbered software released into the public domain"}},
+}
+
+// spdxTag matches an explicit SPDX license identifier within a license file.
+var spdxTag = regexp.MustCompile(`SPDX-License-Identifier:\s*([A-Za-z0-9.+-]+)`)
+
+// DependencyLicenses returns the SPDX identifier of the license of every module
+// dependency, keyed by module path. Licenses are classified heuristically from
+// the module's license file; dependencies without a recognizable license (or
+// whose sources aren't available locally) are reported as NOASSERTION.
+func DependencyLicenses() (map[string]string, error) {
+	deps, err := ModuleDeps()
+	if err != nil {
+		return nil, err
+	}
+	licenses := make(map[string]string, len(deps))
+	for _, dep := range deps {
+		license, err := moduleLicense(dep.Dir)
+		if err != nil {
+			return nil, fmt.Errorf("can't determine license of %s: %v", dep.Path, err)
+		}
+		licenses[dep.Path] = license
+	}
+	return licenses, nil
+}
+
+// moduleLicense classifies the license file in the root of a module directory.
+func moduleLicense(dir string) (string, error) {
+	if dir == "" {
+		return unknownLicense, nil
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	for _, file := range files {
+		name := strings.ToLower(file.Name())
+		if file.IsDir() || !licenseFiles[strings.TrimSuffix(name, filepath.Ext(name))] {
+			continue
+		}
+		text, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return "", err
+		}
+		if license := classifyLicense(string(text)); license != unknownLicense {
+			return license, nil
+		}
+	}
+	return unknownLicense, nil
+}
+
+// classifyLicense returns the SPDX identifier of the given license text.
+func classifyLicense(text string) string {
+	if m := spdxTag.FindStringSubmatch(text); m != nil {
+		return m[1]
+	}
+	text = strings.ToLower(strings.Join(strings.Fields(text), " "))
+	for _, matcher := range licenseMatchers {
+		matched := true
+		for _, phrase := range matcher.phrases {
+			if !strings.Contains(text, phrase) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return matcher.id
+		}
+	}
+	return unknownLicense
+}
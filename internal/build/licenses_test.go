@@ -0,0 +1,101 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"reflect"
+	"testing"
+)
+
+const (
+	testMITLicense = `MIT License
+
+Copyright (c) 2017 The go-ethereum Authors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction.`
+
+	testApacheLicense = `
+                                 Apache License
+                           Version 2.0, January 2004
+                        http://www.apache.org/licenses/`
+
+	testBSDLicense = `Copyright (c) 2009 The Go Authors. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+   * Neither the name of Google Inc. nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.`
+)
+
+// Tests that the licenses of module dependencies are classified from their
+// license files.
+func TestDependencyLicenses(t *testing.T) {
+	_, cleanup := newTestModule(t, map[string]string{
+		"go.mod": `module example.com/m
+
+require (
+	example.com/apache v0.0.0
+	example.com/bsd v0.0.0
+	example.com/mit v0.0.0
+	example.com/none v0.0.0
+)
+
+replace (
+	example.com/apache => ./deps/apache
+	example.com/bsd => ./deps/bsd
+	example.com/mit => ./deps/mit
+	example.com/none => ./deps/none
+)
+`,
+		"deps/apache/go.mod":     "module example.com/apache\n",
+		"deps/apache/LICENSE":    testApacheLicense,
+		"deps/bsd/go.mod":        "module example.com/bsd\n",
+		"deps/bsd/LICENSE.txt":   testBSDLicense,
+		"deps/mit/go.mod":        "module example.com/mit\n",
+		"deps/mit/LICENSE.md":    testMITLicense,
+		"deps/none/go.mod":       "module example.com/none\n",
+		"deps/none/README.md":    "No license here",
+		"deps/none/docs/LICENSE": testMITLicense,
+	})
+	defer cleanup()
+
+	licenses, err := DependencyLicenses()
+	if err != nil {
+		t.Fatalf("failed to determine licenses: %v", err)
+	}
+	want := map[string]string{
+		"example.com/apache": "Apache-2.0",
+		"example.com/bsd":    "BSD-3-Clause",
+		"example.com/mit":    "MIT",
+		"example.com/none":   "NOASSERTION",
+	}
+	if !reflect.DeepEqual(licenses, want) {
+		t.Errorf("licenses mismatch: have %v, want %v", licenses, want)
+	}
+}
+
+// Tests that explicit SPDX identifiers take precedence over text heuristics.
+func TestClassifyLicenseSPDXTag(t *testing.T) {
+	text := "SPDX-License-Identifier: LGPL-3.0-or-later\n\n" + testMITLicense
+	if have, want := classifyLicense(text), "LGPL-3.0-or-later"; have != want {
+		t.Errorf("license mismatch: have %s, want %s", have, want)
+	}
+}
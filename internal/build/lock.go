@@ -0,0 +1,43 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+var LockNoWaitFlag = flag.Bool("lock-nowait", false, "fail instead of waiting if the build lock is held")
+
+// WithLock runs fn while holding an exclusive lock on the file at lockPath,
+// serializing it against other processes (and goroutines) doing the same. If
+// the lock is held elsewhere, WithLock waits for it to be released, or fails
+// immediately if LockNoWaitFlag is set. The lock file is created if needed.
+func WithLock(lockPath string, fn func() error) error {
+	f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := lockFile(f, !*LockNoWaitFlag); err != nil {
+		return fmt.Errorf("can't lock %s: %v", lockPath, err)
+	}
+	defer unlockFile(f)
+	return fn()
+}
@@ -0,0 +1,35 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build !darwin,!dragonfly,!freebsd,!linux,!windows
+
+package build
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// lockFile is unsupported on this platform and always fails.
+func lockFile(f *os.File, wait bool) error {
+	return fmt.Errorf("file locking not supported on %s", runtime.GOOS)
+}
+
+// unlockFile is unsupported on this platform.
+func unlockFile(f *os.File) error {
+	return nil
+}
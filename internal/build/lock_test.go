@@ -0,0 +1,89 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Tests that contending holders of a build lock run serially, and that the lock
+// can be configured to fail instead of waiting.
+func TestWithLock(t *testing.T) {
+	switch runtime.GOOS {
+	case "darwin", "dragonfly", "freebsd", "linux", "windows":
+	default:
+		t.Skipf("file locking not supported on %s", runtime.GOOS)
+	}
+	dir, err := ioutil.TempDir("", "build-lock-")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "build.lock")
+
+	var (
+		lock    sync.Mutex
+		active  int
+		overlap bool
+		pend    sync.WaitGroup
+	)
+	for i := 0; i < 2; i++ {
+		pend.Add(1)
+		go func() {
+			defer pend.Done()
+			err := WithLock(path, func() error {
+				lock.Lock()
+				active++
+				overlap = overlap || active > 1
+				lock.Unlock()
+
+				time.Sleep(50 * time.Millisecond)
+
+				lock.Lock()
+				active--
+				lock.Unlock()
+				return nil
+			})
+			if err != nil {
+				t.Errorf("failed to run locked: %v", err)
+			}
+		}()
+	}
+	pend.Wait()
+	if overlap {
+		t.Errorf("locked functions ran concurrently")
+	}
+	// Hold the lock and ensure a non-waiting acquire fails fast
+	*LockNoWaitFlag = true
+	defer func() { *LockNoWaitFlag = false }()
+
+	err = WithLock(path, func() error {
+		return WithLock(path, func() error {
+			t.Errorf("lock acquired while held")
+			return nil
+		})
+	})
+	if err == nil {
+		t.Errorf("contended non-waiting lock succeeded")
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ManifestEntry describes a single release artifact.
+type ManifestEntry struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	GOOS   string `json:"goos,omitempty"`   // Platform the artifact was built for, if any
+	GOARCH string `json:"goarch,omitempty"` // Architecture the artifact was built for, if any
+}
+
+// Manifest accumulates the artifacts produced by a build, to be handed to upload
+// tooling as a single machine readable listing. It is safe for concurrent use.
+type Manifest struct {
+	lock      sync.Mutex
+	Artifacts []ManifestEntry `json:"artifacts"`
+}
+
+// Add records the file at path, along with its size and SHA-256 digest.
+func (m *Manifest) Add(path string) error {
+	return m.add(path, Target{})
+}
+
+// AddTarget records the file at path like Add, tagging it with the platform it
+// was built for.
+func (m *Manifest) AddTarget(path string, target Target) error {
+	return m.add(path, target)
+}
+
+func (m *Manifest) add(path string, target Target) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	hash, err := fileSHA256(path)
+	if err != nil {
+		return err
+	}
+	entry := ManifestEntry{
+		Path:   filepath.ToSlash(path),
+		Size:   info.Size(),
+		SHA256: hash,
+		GOOS:   target.GOOS,
+		GOARCH: target.GOARCH,
+	}
+	m.lock.Lock()
+	m.Artifacts = append(m.Artifacts, entry)
+	m.lock.Unlock()
+	return nil
+}
+
+// Write serializes the recorded artifacts as JSON into path.
+func (m *Manifest) Write(path string) error {
+	m.lock.Lock()
+	blob, err := json.MarshalIndent(m, "", "  ")
+	m.lock.Unlock()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(blob, '\n'), 0644)
+}
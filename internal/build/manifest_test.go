@@ -0,0 +1,91 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Tests that added artifacts are serialized with their sizes, hashes and
+// platforms.
+func TestManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "build-manifest-")
+	if err != nil {
+		t.Fatalf("failed to create temporary dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	artifacts := map[string]string{
+		"geth-linux-amd64.tar.gz": "linux tarball",
+		"geth-windows-386.zip":    "windows zip archive",
+	}
+	for name, content := range artifacts {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	manifest := new(Manifest)
+	if err := manifest.AddTarget(filepath.Join(dir, "geth-linux-amd64.tar.gz"), Target{"linux", "amd64"}); err != nil {
+		t.Fatalf("failed to add tarball: %v", err)
+	}
+	if err := manifest.Add(filepath.Join(dir, "geth-windows-386.zip")); err != nil {
+		t.Fatalf("failed to add zip: %v", err)
+	}
+	if err := manifest.Add(filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("missing artifact added")
+	}
+	path := filepath.Join(dir, "manifest.json")
+	if err := manifest.Write(path); err != nil {
+		t.Fatalf("failed to write manifest: %v", err)
+	}
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read manifest: %v", err)
+	}
+	var written Manifest
+	if err := json.Unmarshal(blob, &written); err != nil {
+		t.Fatalf("failed to decode manifest: %v", err)
+	}
+	digest := func(s string) string {
+		hash := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(hash[:])
+	}
+	want := []ManifestEntry{
+		{
+			Path:   filepath.ToSlash(filepath.Join(dir, "geth-linux-amd64.tar.gz")),
+			Size:   int64(len(artifacts["geth-linux-amd64.tar.gz"])),
+			SHA256: digest(artifacts["geth-linux-amd64.tar.gz"]),
+			GOOS:   "linux",
+			GOARCH: "amd64",
+		},
+		{
+			Path:   filepath.ToSlash(filepath.Join(dir, "geth-windows-386.zip")),
+			Size:   int64(len(artifacts["geth-windows-386.zip"])),
+			SHA256: digest(artifacts["geth-windows-386.zip"]),
+		},
+	}
+	if !reflect.DeepEqual(written.Artifacts, want) {
+		t.Errorf("manifest mismatch:\nhave %+v\nwant %+v", written.Artifacts, want)
+	}
+}
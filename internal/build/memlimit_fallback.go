@@ -0,0 +1,32 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build !linux
+
+package build
+
+import (
+	"log"
+	"os/exec"
+	"runtime"
+)
+
+// MustRunMemLimit executes the given command like MustRun. Memory limits are
+// only supported on Linux, so elsewhere the command runs unrestricted.
+func MustRunMemLimit(cmd *exec.Cmd, bytes int64) {
+	log.Printf("Warning: memory limits not supported on %s, running %s unrestricted", runtime.GOOS, cmd.Args[0])
+	MustRun(cmd)
+}
@@ -0,0 +1,65 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// MustRunMemLimit executes the given command like MustRun, capping the address
+// space (RLIMIT_AS) of the child at the given number of bytes. The child exits
+// with an allocation failure instead of exhausting the host's memory.
+func MustRunMemLimit(cmd *exec.Cmd, bytes int64) {
+	fmt.Println(">>>", strings.Join(cmd.Args, " "))
+	if *DryRunFlag {
+		return
+	}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := runMemLimit(cmd, bytes); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// runMemLimit executes the given, fully configured command with its address space
+// limited. The standard library can't set the resource limits of a child before
+// it executes, so the limit is raised by a /bin/sh which then execs the program
+// in its place; processes spawned by the program inherit it. The caller's cmd is
+// left untouched apart from its Process and ProcessState.
+func runMemLimit(cmd *exec.Cmd, bytes int64) error {
+	if cmd.Err != nil {
+		return cmd.Err
+	}
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		log.Printf("Warning: can't limit memory of %s, running unrestricted: %v", cmd.Args[0], err)
+		return runAbortable(cmd)
+	}
+	kib := strconv.FormatInt((bytes+1023)/1024, 10)
+	wrapper := exec.Command("/bin/sh", append([]string{"-c", `ulimit -v ` + kib + ` && exec "$@"`, "sh", cmd.Path}, cmd.Args[1:]...)...)
+	wrapper.Env, wrapper.Dir = cmd.Env, cmd.Dir
+	wrapper.Stdin, wrapper.Stdout, wrapper.Stderr = cmd.Stdin, cmd.Stdout, cmd.Stderr
+	wrapper.ExtraFiles, wrapper.SysProcAttr = cmd.ExtraFiles, cmd.SysProcAttr
+
+	err := runAbortable(wrapper)
+	cmd.Process, cmd.ProcessState = wrapper.Process, wrapper.ProcessState
+	return err
+}
@@ -0,0 +1,51 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"bytes"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Tests that the address space limit is in effect from within the child process
+// and that the command line of the caller's command is left unchanged.
+func TestRunMemLimit(t *testing.T) {
+	var stdout bytes.Buffer
+	cmd := exec.Command("cat", "/proc/self/limits")
+	cmd.Stdout = &stdout
+	if err := runMemLimit(cmd, 512*1024*1024); err != nil {
+		t.Fatalf("failed to run limited command: %v", err)
+	}
+	if want := []string{"cat", "/proc/self/limits"}; !reflect.DeepEqual(cmd.Args, want) {
+		t.Errorf("command line modified: have %q, want %q", cmd.Args, want)
+	}
+	if cmd.ProcessState == nil || !cmd.ProcessState.Success() {
+		t.Errorf("process state not reported: %v", cmd.ProcessState)
+	}
+	var limit []string
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		if strings.HasPrefix(line, "Max address space") {
+			limit = strings.Fields(strings.TrimPrefix(line, "Max address space"))
+		}
+	}
+	if want := []string{"536870912", "536870912", "bytes"}; !reflect.DeepEqual(limit, want) {
+		t.Errorf("child address space limit mismatch: have %q, want %q", limit, want)
+	}
+}
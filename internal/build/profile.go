@@ -0,0 +1,87 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime/pprof"
+)
+
+// CPUProfileEnv is the environment variable instructing build tools to write a
+// CPU profile into the file it names. Go programs opt in via StartCPUProfile.
+const CPUProfileEnv = "BUILD_CPUPROFILE"
+
+// RunWithProfile executes the given command with CPUProfileEnv pointing at
+// cpuProfile (creating parent directories as needed) and verifies that the
+// command actually wrote a non-empty profile there.
+func RunWithProfile(cmd *exec.Cmd, cpuProfile string) error {
+	path, err := filepath.Abs(cpuProfile)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// Remove any stale profile so the check below can't be fooled by it
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, CPUProfileEnv+"="+path)
+	if err := runCommand(cmd); err != nil {
+		return err
+	}
+	if *DryRunFlag {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("%s didn't write CPU profile: %v", cmd.Args[0], err)
+	}
+	if info.Size() == 0 {
+		return fmt.Errorf("%s wrote empty CPU profile %s", cmd.Args[0], path)
+	}
+	return nil
+}
+
+// StartCPUProfile starts CPU profiling of the current process into the file
+// named by CPUProfileEnv, if set. The returned function stops profiling and must
+// be called before the process exits.
+func StartCPUProfile() (func() error, error) {
+	path := os.Getenv(CPUProfileEnv)
+	if path == "" {
+		return func() error { return nil }, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := pprof.StartCPUProfile(f); err != nil {
+		f.Close()
+		os.Remove(path)
+		return nil, err
+	}
+	return func() error {
+		pprof.StopCPUProfile()
+		return f.Close()
+	}, nil
+}
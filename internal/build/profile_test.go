@@ -0,0 +1,88 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Tests that profiled runs pass the profile path to the tool and fail if no
+// profile was produced.
+func TestRunWithProfile(t *testing.T) {
+	skipNoShell(t)
+
+	dir, err := ioutil.TempDir("", "build-profile-")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tool := writeStub(t, dir, "abigen", `
+[ "$1" = "--lazy" ] && exit 0
+echo "$`+CPUProfileEnv+`" > "$(dirname "$0")/env"
+printf 'pprof' > "$`+CPUProfileEnv+`"
+`)
+	profile := filepath.Join(dir, "profiles", "abigen.pprof")
+	if err := RunWithProfile(exec.Command(tool), profile); err != nil {
+		t.Fatalf("failed to run profiled tool: %v", err)
+	}
+	if have, want := strings.TrimSpace(mustReadFile(t, filepath.Join(dir, "env"))), profile; have != want {
+		t.Errorf("profile env mismatch: have %s, want %s", have, want)
+	}
+	// A stale profile from a previous run must not satisfy the check
+	err = RunWithProfile(exec.Command(tool, "--lazy"), profile)
+	if err == nil || !strings.Contains(err.Error(), "didn't write CPU profile") {
+		t.Errorf("missing profile error mismatch: %v", err)
+	}
+}
+
+// Tests that CPU profiling is only started if requested through the environment.
+func TestStartCPUProfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "build-profile-")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	old := os.Getenv(CPUProfileEnv)
+	defer os.Setenv(CPUProfileEnv, old)
+
+	os.Setenv(CPUProfileEnv, "")
+	stop, err := StartCPUProfile()
+	if err != nil {
+		t.Fatalf("failed to skip profiling: %v", err)
+	}
+	if err := stop(); err != nil {
+		t.Errorf("failed to stop disabled profiling: %v", err)
+	}
+	profile := filepath.Join(dir, "cpu.pprof")
+	os.Setenv(CPUProfileEnv, profile)
+	if stop, err = StartCPUProfile(); err != nil {
+		t.Fatalf("failed to start profiling: %v", err)
+	}
+	if err := stop(); err != nil {
+		t.Fatalf("failed to stop profiling: %v", err)
+	}
+	if info, err := os.Stat(profile); err != nil || info.Size() == 0 {
+		t.Errorf("profile not written: %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"bytes"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// openPTY allocates a new pseudo terminal pair, following what grantpt(3),
+// unlockpt(3) and ptsname(3) do on macOS.
+func openPTY() (*os.File, *os.File, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := ioctl(master.Fd(), syscall.TIOCPTYGRANT, 0); err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+	if err := ioctl(master.Fd(), syscall.TIOCPTYUNLK, 0); err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+	var name [128]byte
+	if err := ioctl(master.Fd(), syscall.TIOCPTYGNAME, uintptr(unsafe.Pointer(&name[0]))); err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+	end := bytes.IndexByte(name[:], 0)
+	if end < 0 {
+		master.Close()
+		return nil, nil, syscall.EINVAL
+	}
+	slave, err := os.OpenFile(string(name[:end]), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+	return master, slave, nil
+}
@@ -0,0 +1,32 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build !darwin,!freebsd,!linux
+
+package build
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+// RunWithPTY executes the given command attached to a pseudo terminal. Pseudo
+// terminals are only implemented for Linux, macOS and FreeBSD, so this always
+// returns an error.
+func RunWithPTY(cmd *exec.Cmd) (string, error) {
+	return "", fmt.Errorf("pseudo terminals not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,72 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build darwin freebsd linux
+
+package build
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// RunWithPTY executes the given command attached to a freshly allocated pseudo
+// terminal and returns everything it printed, so that tools behave as if they
+// were run interactively. Note, the terminal translates line endings, so the
+// output uses "\r\n". Pseudo terminals are supported on Linux, macOS and
+// FreeBSD, elsewhere an error is returned.
+func RunWithPTY(cmd *exec.Cmd) (string, error) {
+	fmt.Println(">>>", strings.Join(cmd.Args, " "))
+	if *DryRunFlag {
+		return "", nil
+	}
+	master, slave, err := openPTY()
+	if err != nil {
+		return "", err
+	}
+	defer master.Close()
+
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = slave, slave, slave
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+	wait, err := startAbortable(cmd)
+	slave.Close()
+	if err != nil {
+		return "", err
+	}
+	// Reading the master fails with EIO once all slave descriptors are closed,
+	// which is the regular end of the output stream.
+	var output bytes.Buffer
+	if _, err := io.Copy(&output, master); err != nil {
+		if perr, ok := err.(*os.PathError); !ok || perr.Err != syscall.EIO {
+			wait()
+			return output.String(), err
+		}
+	}
+	return output.String(), wait()
+}
+
+// ioctl issues a device specific request on a file descriptor.
+func ioctl(fd, req, arg uintptr) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, arg); errno != 0 {
+		return errno
+	}
+	return nil
+}
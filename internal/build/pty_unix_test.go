@@ -0,0 +1,52 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build darwin freebsd linux
+
+package build
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+// Tests that commands run via RunWithPTY detect a terminal.
+func TestRunWithPTY(t *testing.T) {
+	cmd := exec.Command("sh", "-c", "if [ -t 0 ] && [ -t 1 ]; then echo tty; else echo notty; fi")
+	have, err := RunWithPTY(cmd)
+	if err != nil {
+		t.Fatalf("failed to run command: %v", err)
+	}
+	if have != "tty\r\n" {
+		t.Errorf("output mismatch: have %q, want %q", have, "tty\r\n")
+	}
+}
+
+// Tests that commands run via RunWithPTY are killed when the build is aborted.
+func TestRunWithPTYAbortable(t *testing.T) {
+	defer resetAbort()
+	abortAfter(100 * time.Millisecond)
+
+	start := time.Now()
+	if _, err := RunWithPTY(exec.Command("sleep", "30")); err != context.Canceled {
+		t.Errorf("aborted command error mismatch: have %v, want %v", err, context.Canceled)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("command not killed on abort, took %v", elapsed)
+	}
+}
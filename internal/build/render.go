@@ -0,0 +1,447 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"unicode/utf8"
+
+	"golang.org/x/tools/imports"
+)
+
+// templateKey identifies a parsed template in the templateCache.
+type templateKey struct {
+	left, right string // Action delimiters
+	content     string // Template source
+	funcs       string // Sorted names of the functions available
+}
+
+var (
+	templateCacheLock sync.Mutex
+	templateCache     = make(map[templateKey]*template.Template)
+
+	// parseTemplate parses source into the given template. It is a variable so
+	// tests can observe how often templates are actually parsed.
+	parseTemplate = func(tpl *template.Template, content string) (*template.Template, error) {
+		return tpl.Parse(content)
+	}
+)
+
+// ClearTemplateCache drops all templates parsed by the render helpers.
+func ClearTemplateCache() {
+	templateCacheLock.Lock()
+	defer templateCacheLock.Unlock()
+
+	templateCache = make(map[templateKey]*template.Template)
+}
+
+// parseCached returns the given template string parsed with the requested
+// delimiters and functions, reusing a previous parse of the same source. Since
+// the function values may differ between calls (e.g. a fresh SeqFuncs counter),
+// a clone of the cached template bound to the given funcs is returned.
+func parseCached(left, right, content string, funcs template.FuncMap) (*template.Template, error) {
+	names := make([]string, 0, len(funcs))
+	for name := range funcs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	key := templateKey{left, right, content, strings.Join(names, ",")}
+
+	templateCacheLock.Lock()
+	defer templateCacheLock.Unlock()
+
+	tpl, ok := templateCache[key]
+	if !ok {
+		var err error
+		if tpl, err = parseTemplate(template.New("").Delims(left, right).Funcs(funcs), content); err != nil {
+			return nil, err
+		}
+		templateCache[key] = tpl
+	}
+	clone, err := tpl.Clone()
+	if err != nil {
+		return nil, err
+	}
+	return clone.Funcs(funcs), nil
+}
+
+// RenderWithDelims renders the given template string into outputFile, using
+// left and right as the action delimiters instead of the default "{{" and "}}".
+// This allows generating files which themselves contain literal brace pairs.
+func RenderWithDelims(left, right, templateContent, outputFile string, outputPerm os.FileMode, x interface{}) error {
+	tpl, err := parseCached(left, right, templateContent, nil)
+	if err != nil {
+		return err
+	}
+	return renderFile(tpl, outputFile, outputPerm, x)
+}
+
+// RenderAndRun renders the given template string into outputFile and, if that
+// succeeded, executes cmd (typically a compiler or checker operating on the
+// freshly generated file).
+func RenderAndRun(templateContent, outputFile string, outputPerm os.FileMode, x interface{}, cmd *exec.Cmd) error {
+	tpl, err := parseCached("", "", templateContent, nil)
+	if err != nil {
+		return err
+	}
+	if err := renderFile(tpl, outputFile, outputPerm, x); err != nil {
+		return err
+	}
+	return runCommand(cmd)
+}
+
+// RenderTree mirrors the directory tree srcDir into dstDir. Files with a .tmpl
+// suffix are rendered with x and written with the suffix stripped and the given
+// permissions, all other files are copied verbatim keeping their permissions.
+// Like all render outputs, both are confined to RenderRoot if it is set and
+// must not exist yet.
+func RenderTree(srcDir, dstDir string, outputPerm os.FileMode, x interface{}) error {
+	return filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		if !strings.HasSuffix(rel, ".tmpl") {
+			dst, err := renderPath(filepath.Join(dstDir, rel))
+			if err != nil {
+				return err
+			}
+			return copyExclusive(dst, path, info.Mode().Perm())
+		}
+		tpl, err := template.ParseFiles(path)
+		if err != nil {
+			return err
+		}
+		return renderFile(tpl, filepath.Join(dstDir, strings.TrimSuffix(rel, ".tmpl")), outputPerm, x)
+	})
+}
+
+// DefaultFuncs returns a set of commonly needed string manipulation functions
+// for templates. Argument orders are chosen so the functions chain in pipelines,
+// e.g. {{.Name | replace "-" "_" | upper}}. The json function encodes its
+// argument as a JSON value, for safely embedding data into JSON documents, and
+// plural selects between a singular and plural word form, as in
+// {{.N}} {{plural .N "file" "files"}}.
+func DefaultFuncs() template.FuncMap {
+	return template.FuncMap{
+		"upper": strings.ToUpper,
+		"lower": strings.ToLower,
+		"title": strings.Title,
+		"trim":  strings.TrimSpace,
+		"replace": func(old, new, s string) string {
+			return strings.Replace(s, old, new, -1)
+		},
+		"join": func(sep string, elems []string) string {
+			return strings.Join(elems, sep)
+		},
+		"indent": func(spaces int, s string) string {
+			pad := strings.Repeat(" ", spaces)
+			return pad + strings.Replace(s, "\n", "\n"+pad, -1)
+		},
+		"quote": func(s string) string {
+			return fmt.Sprintf("%q", s)
+		},
+		"json": func(v interface{}) (string, error) {
+			blob, err := json.Marshal(v)
+			return string(blob), err
+		},
+		"plural": func(count int, singular, plural string) string {
+			if count == 1 {
+				return singular
+			}
+			return plural
+		},
+	}
+}
+
+// SeqFuncs returns a template function set containing a stateful "seq" counter,
+// yielding successive integers starting at base on every invocation. Since the
+// counter lives in the returned map, a fresh set must be created per render to
+// restart the sequence, e.g. RenderWithFuncs(tmpl, out, perm, SeqFuncs(0), x).
+func SeqFuncs(base int) template.FuncMap {
+	next := base
+	return template.FuncMap{
+		"seq": func() int {
+			next++
+			return next - 1
+		},
+	}
+}
+
+// RenderWithFuncs renders the given template string into outputFile, making the
+// DefaultFuncs and the given extra functions available to it. Extra functions
+// override default ones with the same name.
+func RenderWithFuncs(templateContent, outputFile string, outputPerm os.FileMode, funcs template.FuncMap, x interface{}) error {
+	merged := DefaultFuncs()
+	for name, fn := range funcs {
+		merged[name] = fn
+	}
+	tpl, err := parseCached("", "", templateContent, merged)
+	if err != nil {
+		return err
+	}
+	return renderFile(tpl, outputFile, outputPerm, x)
+}
+
+// RenderDefault renders the given template string into outputFile with the
+// DefaultFuncs available to it.
+func RenderDefault(templateContent, outputFile string, outputPerm os.FileMode, x interface{}) error {
+	return RenderWithFuncs(templateContent, outputFile, outputPerm, nil, x)
+}
+
+// RenderValidated renders the given template string into outputFile after
+// checking that the data x (a struct or a map keyed by strings) has a non-zero
+// value for every required field. Nothing is written if fields are missing.
+func RenderValidated(templateContent, outputFile string, outputPerm os.FileMode, x interface{}, required []string) error {
+	if missing := missingFields(x, required); len(missing) > 0 {
+		return fmt.Errorf("template data missing required fields: %s", strings.Join(missing, ", "))
+	}
+	tpl, err := parseCached("", "", templateContent, nil)
+	if err != nil {
+		return err
+	}
+	return renderFile(tpl, outputFile, outputPerm, x)
+}
+
+// missingFields returns the names from required which are absent or zero in x.
+func missingFields(x interface{}, required []string) []string {
+	v := reflect.ValueOf(x)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	var missing []string
+	for _, name := range required {
+		var field reflect.Value
+		switch v.Kind() {
+		case reflect.Struct:
+			if sf, ok := v.Type().FieldByName(name); ok && sf.PkgPath == "" {
+				field = v.FieldByIndex(sf.Index)
+			}
+		case reflect.Map:
+			if v.Type().Key().Kind() == reflect.String {
+				field = v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+			}
+		}
+		if !field.IsValid() || isZeroValue(field) {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// isZeroValue reports whether v holds the zero value of its type.
+func isZeroValue(v reflect.Value) bool {
+	for v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return true
+		}
+		v = v.Elem()
+	}
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
+
+// RenderEach renders the given template string once for every item, writing
+// each result into the file computed by outputPath for that item. If any render
+// fails, all files written so far are removed again.
+func RenderEach(templateContent string, items []interface{}, outputPath func(interface{}) string, outputPerm os.FileMode) error {
+	tpl, err := parseCached("", "", templateContent, nil)
+	if err != nil {
+		return err
+	}
+	var written []string
+	for _, item := range items {
+		path := outputPath(item)
+		file, err := renderPath(path)
+		if err == nil {
+			var buf bytes.Buffer
+			if err = tpl.Execute(&buf, item); err == nil {
+				err = writeExclusive(file, outputPerm, buf.Bytes())
+			}
+		}
+		if err != nil {
+			for _, file := range written {
+				os.Remove(file)
+			}
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		// The file was created exclusively, so it's ours to roll back
+		written = append(written, file)
+	}
+	return nil
+}
+
+// RenderEnsureNewline renders the given template string into outputFile,
+// normalizing the output to end with exactly one newline.
+func RenderEnsureNewline(templateContent, outputFile string, outputPerm os.FileMode, x interface{}) error {
+	tpl, err := parseCached("", "", templateContent, nil)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, x); err != nil {
+		return err
+	}
+	output := append(bytes.TrimRight(buf.Bytes(), "\n"), '\n')
+	return writeRendered(outputFile, outputPerm, output)
+}
+
+// RenderUTF8 renders the given template string into outputFile, refusing to
+// write output which isn't valid UTF-8 (e.g. due to corrupt data). The error
+// reports the offset of the first invalid byte.
+func RenderUTF8(templateContent, outputFile string, outputPerm os.FileMode, x interface{}) error {
+	tpl, err := parseCached("", "", templateContent, nil)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, x); err != nil {
+		return err
+	}
+	if output := buf.Bytes(); !utf8.Valid(output) {
+		offset := 0
+		for {
+			r, size := utf8.DecodeRune(output[offset:])
+			if r == utf8.RuneError && size == 1 {
+				break
+			}
+			offset += size
+		}
+		return fmt.Errorf("%s: rendered output is not valid UTF-8 at byte offset %d", outputFile, offset)
+	}
+	return writeRendered(outputFile, outputPerm, buf.Bytes())
+}
+
+// RenderGoImports renders the given template string as Go source into outputFile,
+// fixing up its imports (adding missing and removing unused ones) and formatting
+// it like goimports does.
+func RenderGoImports(templateContent, outputFile string, outputPerm os.FileMode, x interface{}) error {
+	tpl, err := parseCached("", "", templateContent, nil)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, x); err != nil {
+		return err
+	}
+	code, err := imports.Process("", buf.Bytes(), nil)
+	if err != nil {
+		return fmt.Errorf("%s: generated invalid Go source: %v", outputFile, err)
+	}
+	return writeRendered(outputFile, outputPerm, code)
+}
+
+// RenderJob describes a single template rendering for RenderBatch.
+type RenderJob struct {
+	Template string      // Template content to render
+	Output   string      // Path of the file to create
+	Data     interface{} // Data to execute the template with
+	Perm     os.FileMode // Permissions of the created file
+}
+
+// RenderBatch renders all the given jobs, running up to concurrency of them in
+// parallel. Jobs must write distinct output files. The failures of all jobs are
+// aggregated into the returned error.
+func RenderBatch(jobs []RenderJob, concurrency int) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	var (
+		errs  = make([]error, len(jobs))
+		tasks = make(chan int)
+		pend  sync.WaitGroup
+	)
+	for i := 0; i < concurrency; i++ {
+		pend.Add(1)
+		go func() {
+			defer pend.Done()
+			for idx := range tasks {
+				job := jobs[idx]
+				tpl, err := parseCached("", "", job.Template, nil)
+				if err == nil {
+					err = renderFile(tpl, job.Output, job.Perm, job.Data)
+				}
+				errs[idx] = err
+			}
+		}()
+	}
+	for i := range jobs {
+		tasks <- i
+	}
+	close(tasks)
+	pend.Wait()
+
+	var failures []string
+	for i, err := range errs {
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", jobs[i].Output, err))
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("%d of %d renders failed:\n  %s", len(failures), len(jobs), strings.Join(failures, "\n  "))
+	}
+	return nil
+}
+
+// RenderValidatedJSON renders the given template string as JSON into outputFile
+// after validating the output against the JSON schema stored at schemaPath.
+// Nothing is written if the output is malformed or violates the schema, the
+// returned error lists all the violations found. Schemas using validation
+// keywords the validator doesn't implement (e.g. $ref or oneOf) are rejected.
+func RenderValidatedJSON(templateContent, outputFile, schemaPath string, outputPerm os.FileMode, x interface{}) error {
+	blob, err := ioutil.ReadFile(schemaPath)
+	if err != nil {
+		return err
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(blob, &schema); err != nil {
+		return fmt.Errorf("%s: invalid JSON schema: %v", schemaPath, err)
+	}
+	tpl, err := parseCached("", "", templateContent, nil)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, x); err != nil {
+		return err
+	}
+	var value interface{}
+	if err := json.Unmarshal(buf.Bytes(), &value); err != nil {
+		return fmt.Errorf("%s: rendered output is not valid JSON: %v", outputFile, err)
+	}
+	errs, err := validateJSONSchema(schema, value)
+	if err != nil {
+		return fmt.Errorf("%s: %v", schemaPath, err)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s: rendered output violates schema %s:\n  %s", outputFile, schemaPath, strings.Join(errs, "\n  "))
+	}
+	return writeRendered(outputFile, outputPerm, buf.Bytes())
+}
@@ -0,0 +1,701 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+// Tests that templates using custom delimiters can emit the default delimiters
+// as literal text.
+func TestRenderWithDelims(t *testing.T) {
+	dir, err := ioutil.TempDir("", "build-render-")
+	if err != nil {
+		t.Fatalf("failed to create temporary dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, "chart.yaml")
+	tmpl := "name: [[ .Name ]]\nvalue: {{ .Values.name }}\n"
+	if err := RenderWithDelims("[[", "]]", tmpl, out, 0644, map[string]string{"Name": "geth"}); err != nil {
+		t.Fatalf("failed to render template: %v", err)
+	}
+	have, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatalf("failed to read rendered file: %v", err)
+	}
+	if want := "name: geth\nvalue: {{ .Values.name }}\n"; string(have) != want {
+		t.Errorf("rendered output mismatch: have %q, want %q", have, want)
+	}
+}
+
+// Tests that render outputs are confined to RenderRoot when one is configured.
+func TestRenderRoot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "build-render-")
+	if err != nil {
+		t.Fatalf("failed to create temporary dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	RenderRoot = dir
+	defer func() { RenderRoot = "" }()
+
+	if err := RenderWithDelims("{{", "}}", "ok", "sub/out.txt", 0644, nil); err != nil {
+		t.Fatalf("failed to render inside root: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "sub", "out.txt")); err != nil {
+		t.Errorf("rendered file not placed under root: %v", err)
+	}
+	if err := RenderWithDelims("{{", "}}", "evil", "../escape.txt", 0644, nil); err == nil {
+		t.Errorf("render escaping the root succeeded")
+	}
+}
+
+// Tests that errors from both the render and the run phase are propagated, and
+// that the command only runs after a successful render.
+func TestRenderAndRun(t *testing.T) {
+	skipNoShell(t)
+
+	dir, err := ioutil.TempDir("", "build-render-")
+	if err != nil {
+		t.Fatalf("failed to create temporary dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, "main.go")
+	tmpl := "package {{.}}\n\nfunc main() {}\n"
+	if err := RenderAndRun(tmpl, out, 0644, "main", exec.Command("grep", "-q", "^package main$", out)); err != nil {
+		t.Fatalf("failed to render and check: %v", err)
+	}
+	// Rendering over an existing file must fail without running the command
+	marker := filepath.Join(dir, "ran")
+	if err := RenderAndRun(tmpl, out, 0644, "main", exec.Command("touch", marker)); err == nil {
+		t.Errorf("render failure not reported")
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Errorf("command executed after failed render")
+	}
+	// Command failures must be reported too
+	out2 := filepath.Join(dir, "other.go")
+	if err := RenderAndRun(tmpl, out2, 0644, "other", exec.Command("grep", "-q", "^package main$", out2)); err == nil {
+		t.Errorf("command failure not reported")
+	}
+}
+
+// Tests that a directory of templates and static files is mirrored with the
+// templates rendered.
+func TestRenderTree(t *testing.T) {
+	src, err := ioutil.TempDir("", "build-render-src-")
+	if err != nil {
+		t.Fatalf("failed to create source dir: %v", err)
+	}
+	defer os.RemoveAll(src)
+	dst, err := ioutil.TempDir("", "build-render-dst-")
+	if err != nil {
+		t.Fatalf("failed to create destination dir: %v", err)
+	}
+	defer os.RemoveAll(dst)
+
+	files := map[string]string{
+		"README.md.tmpl":       "# {{.Name}}",
+		"cmd/main.go.tmpl":     "package main // {{.Name}}",
+		"static/logo.txt":      "{{not a template}}",
+		"static/deep/data.bin": "raw",
+	}
+	for name, content := range files {
+		path := filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create dir for %s: %v", name, err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	if err := RenderTree(src, dst, 0644, map[string]string{"Name": "geth"}); err != nil {
+		t.Fatalf("failed to render tree: %v", err)
+	}
+	want := map[string]string{
+		"README.md":            "# geth",
+		"cmd/main.go":          "package main // geth",
+		"static/logo.txt":      "{{not a template}}",
+		"static/deep/data.bin": "raw",
+	}
+	for name, content := range want {
+		have, err := ioutil.ReadFile(filepath.Join(dst, filepath.FromSlash(name)))
+		if err != nil {
+			t.Errorf("%s: missing output: %v", name, err)
+		} else if string(have) != content {
+			t.Errorf("%s: content mismatch: have %q, want %q", name, have, content)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dst, "README.md.tmpl")); err == nil {
+		t.Errorf("template copied verbatim")
+	}
+	// Re-rendering into the existing tree must fail for both kinds of files,
+	// without overwriting the existing outputs
+	if err := RenderTree(src, dst, 0644, map[string]string{"Name": "swarm"}); err == nil {
+		t.Errorf("re-render over existing templates succeeded")
+	}
+	if have := mustReadFile(t, filepath.Join(dst, "README.md")); have != "# geth" {
+		t.Errorf("existing template output overwritten: %q", have)
+	}
+	for _, name := range []string{"README.md", "cmd/main.go"} {
+		os.Remove(filepath.Join(dst, filepath.FromSlash(name)))
+	}
+	logo := filepath.Join(dst, "static", "logo.txt")
+	if err := ioutil.WriteFile(logo, []byte("customized"), 0644); err != nil {
+		t.Fatalf("failed to modify static output: %v", err)
+	}
+	if err := RenderTree(src, dst, 0644, map[string]string{"Name": "swarm"}); err == nil {
+		t.Errorf("re-render over existing static files succeeded")
+	}
+	if have := mustReadFile(t, logo); have != "customized" {
+		t.Errorf("existing static output overwritten: %q", have)
+	}
+}
+
+// Tests that both rendered and static files of a tree are confined to RenderRoot.
+func TestRenderTreeRoot(t *testing.T) {
+	src, err := ioutil.TempDir("", "build-render-src-")
+	if err != nil {
+		t.Fatalf("failed to create source dir: %v", err)
+	}
+	defer os.RemoveAll(src)
+	root, err := ioutil.TempDir("", "build-render-root-")
+	if err != nil {
+		t.Fatalf("failed to create render root: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	for name, content := range map[string]string{"README.md.tmpl": "# {{.}}", "logo.txt": "logo"} {
+		if err := ioutil.WriteFile(filepath.Join(src, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	RenderRoot = root
+	defer func() { RenderRoot = "" }()
+
+	if err := RenderTree(src, "site", 0644, "geth"); err != nil {
+		t.Fatalf("failed to render tree: %v", err)
+	}
+	for _, name := range []string{"README.md", "logo.txt"} {
+		if _, err := os.Stat(filepath.Join(root, "site", name)); err != nil {
+			t.Errorf("%s: output not placed under root: %v", name, err)
+		}
+	}
+	// Static files must not escape the root either
+	os.Remove(filepath.Join(src, "README.md.tmpl"))
+	if err := RenderTree(src, filepath.Join("..", "escape"), 0644, "geth"); err == nil {
+		t.Errorf("static file escaping the root copied")
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(root), "escape", "logo.txt")); err == nil {
+		os.RemoveAll(filepath.Join(filepath.Dir(root), "escape"))
+		t.Errorf("static file written outside the root")
+	}
+}
+
+// Tests that the default template functions are available and chain properly.
+func TestRenderDefault(t *testing.T) {
+	dir, err := ioutil.TempDir("", "build-render-")
+	if err != nil {
+		t.Fatalf("failed to create temporary dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tmpl := `{{.Name | replace "-" "_" | upper}}
+{{title .Name}} {{lower "GETH"}} [{{trim "  x  "}}]
+{{join ", " .Tags}}
+{{quote .Name}}
+{{indent 2 "a\nb"}}`
+	data := map[string]interface{}{"Name": "go-ethereum", "Tags": []string{"a", "b"}}
+	out := filepath.Join(dir, "default.txt")
+	if err := RenderDefault(tmpl, out, 0644, data); err != nil {
+		t.Fatalf("failed to render template: %v", err)
+	}
+	want := "GO_ETHEREUM\nGo-Ethereum geth [x]\na, b\n\"go-ethereum\"\n  a\n  b"
+	if have, _ := ioutil.ReadFile(out); string(have) != want {
+		t.Errorf("rendered output mismatch:\nhave %q\nwant %q", have, want)
+	}
+	// Extra functions should be able to override the defaults
+	out = filepath.Join(dir, "funcs.txt")
+	funcs := template.FuncMap{"upper": func(s string) string { return "overridden" }}
+	if err := RenderWithFuncs("{{upper .}} {{lower .}}", out, 0644, funcs, "X"); err != nil {
+		t.Fatalf("failed to render template: %v", err)
+	}
+	if have, _ := ioutil.ReadFile(out); string(have) != "overridden x" {
+		t.Errorf("rendered output mismatch: have %q, want %q", have, "overridden x")
+	}
+}
+
+// Tests that the json function produces valid JSON from arbitrary strings.
+func TestRenderJSONFunc(t *testing.T) {
+	dir, err := ioutil.TempDir("", "build-render-")
+	if err != nil {
+		t.Fatalf("failed to create temporary dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	data := map[string]interface{}{
+		"Name":  "say \"hi\"\n\tand <leave>",
+		"Ports": []int{30303, 8545},
+	}
+	out := filepath.Join(dir, "config.json")
+	if err := RenderDefault(`{"name": {{json .Name}}, "ports": {{json .Ports}}}`, out, 0644, data); err != nil {
+		t.Fatalf("failed to render template: %v", err)
+	}
+	blob, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatalf("failed to read rendered output: %v", err)
+	}
+	var have struct {
+		Name  string
+		Ports []int
+	}
+	if err := json.Unmarshal(blob, &have); err != nil {
+		t.Fatalf("rendered output is not valid JSON: %v\n%s", err, blob)
+	}
+	if have.Name != data["Name"] {
+		t.Errorf("name mismatch: have %q, want %q", have.Name, data["Name"])
+	}
+	if !reflect.DeepEqual(have.Ports, data["Ports"]) {
+		t.Errorf("ports mismatch: have %v, want %v", have.Ports, data["Ports"])
+	}
+}
+
+// Tests that the plural function selects the word form matching the count.
+func TestRenderPluralFunc(t *testing.T) {
+	dir, err := ioutil.TempDir("", "build-render-")
+	if err != nil {
+		t.Fatalf("failed to create temporary dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for i, want := range []string{"0 files", "1 file", "2 files"} {
+		out := filepath.Join(dir, fmt.Sprintf("plural-%d.txt", i))
+		if err := RenderDefault(`{{.}} {{plural . "file" "files"}}`, out, 0644, i); err != nil {
+			t.Fatalf("count %d: failed to render template: %v", i, err)
+		}
+		if have, _ := ioutil.ReadFile(out); string(have) != want {
+			t.Errorf("count %d: output mismatch: have %q, want %q", i, have, want)
+		}
+	}
+}
+
+// Tests that templates are only rendered if all required data fields are set.
+func TestRenderValidated(t *testing.T) {
+	dir, err := ioutil.TempDir("", "build-render-")
+	if err != nil {
+		t.Fatalf("failed to create temporary dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tmpl := "{{.Name}} {{.Version}}"
+	out := filepath.Join(dir, "missing.txt")
+
+	data := map[string]interface{}{"Name": "geth", "Version": "", "Extra": 1}
+	err = RenderValidated(tmpl, out, 0644, data, []string{"Name", "Version", "Commit"})
+	if err == nil {
+		t.Fatalf("incomplete data accepted")
+	}
+	if !strings.Contains(err.Error(), "Version, Commit") {
+		t.Errorf("error doesn't list the missing fields: %v", err)
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("output written despite missing fields: %v", err)
+	}
+	// Structs must be validated the same way
+	type meta struct {
+		Name, Version string
+	}
+	out = filepath.Join(dir, "ok.txt")
+	if err := RenderValidated(tmpl, out, 0644, &meta{"geth", "1.6.1"}, []string{"Name", "Version"}); err != nil {
+		t.Fatalf("complete data rejected: %v", err)
+	}
+	if have, _ := ioutil.ReadFile(out); string(have) != "geth 1.6.1" {
+		t.Errorf("rendered output mismatch: have %q, want %q", have, "geth 1.6.1")
+	}
+}
+
+// Tests that a template is rendered into a separate file for every item and
+// that failures roll back already written files.
+func TestRenderEach(t *testing.T) {
+	dir, err := ioutil.TempDir("", "build-render-")
+	if err != nil {
+		t.Fatalf("failed to create temporary dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	items := []interface{}{"eth", "les", "whisper"}
+	path := func(item interface{}) string {
+		return filepath.Join(dir, item.(string), "gen.go")
+	}
+	if err := RenderEach("package {{.}}\n", items, path, 0644); err != nil {
+		t.Fatalf("failed to render items: %v", err)
+	}
+	for _, item := range items {
+		want := "package " + item.(string) + "\n"
+		if have, err := ioutil.ReadFile(path(item)); err != nil || string(have) != want {
+			t.Errorf("%s: output mismatch: have %q, %v, want %q", item, have, err, want)
+		}
+	}
+	// Rendering over an existing file must roll back the new ones
+	items = []interface{}{"shh", "p2p", "eth"}
+	if err := RenderEach("package {{.}}\n", items, path, 0644); err == nil {
+		t.Fatalf("conflicting render succeeded")
+	}
+	for _, item := range items[:2] {
+		if _, err := os.Stat(path(item)); !os.IsNotExist(err) {
+			t.Errorf("%s: output not rolled back: %v", item, err)
+		}
+	}
+}
+
+// Tests that a failing batch rolls back the outputs it created within RenderRoot,
+// leaving same named files outside of the root alone.
+func TestRenderEachRoot(t *testing.T) {
+	root, err := ioutil.TempDir("", "build-render-root-")
+	if err != nil {
+		t.Fatalf("failed to create render root: %v", err)
+	}
+	defer os.RemoveAll(root)
+	cwd, err := ioutil.TempDir("", "build-render-cwd-")
+	if err != nil {
+		t.Fatalf("failed to create working dir: %v", err)
+	}
+	defer os.RemoveAll(cwd)
+
+	old, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get working directory: %v", err)
+	}
+	if err := os.Chdir(cwd); err != nil {
+		t.Fatalf("failed to enter working dir: %v", err)
+	}
+	defer os.Chdir(old)
+
+	// Unrelated files in the working directory sharing the output names
+	for _, name := range []string{"eth.go", "les.go"} {
+		if err := ioutil.WriteFile(name, []byte("keep"), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	// A pre-existing output within the root, which makes the batch fail
+	if err := ioutil.WriteFile(filepath.Join(root, "shh.go"), []byte("package shh\n"), 0644); err != nil {
+		t.Fatalf("failed to write existing output: %v", err)
+	}
+	RenderRoot = root
+	defer func() { RenderRoot = "" }()
+
+	path := func(item interface{}) string { return item.(string) + ".go" }
+	if err := RenderEach("package {{.}}\n", []interface{}{"eth", "les", "shh"}, path, 0644); err == nil {
+		t.Fatalf("conflicting render succeeded")
+	}
+	for _, name := range []string{"eth.go", "les.go"} {
+		if _, err := os.Stat(filepath.Join(root, name)); !os.IsNotExist(err) {
+			t.Errorf("%s: output within root not rolled back: %v", name, err)
+		}
+		if have := mustReadFile(t, name); have != "keep" {
+			t.Errorf("%s: unrelated file modified: %q", name, have)
+		}
+	}
+	if have := mustReadFile(t, filepath.Join(root, "shh.go")); have != "package shh\n" {
+		t.Errorf("pre-existing output modified: %q", have)
+	}
+}
+
+// Tests that rendered outputs end in exactly one newline.
+func TestRenderEnsureNewline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "build-render-")
+	if err != nil {
+		t.Fatalf("failed to create temporary dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		tmpl string
+		want string
+	}{
+		{"package {{.}}\n", "package main\n"},
+		{"package {{.}}\n\n\n", "package main\n"},
+		{"package {{.}}", "package main\n"},
+		{"\n\npackage {{.}}", "\n\npackage main\n"},
+	}
+	for i, tt := range tests {
+		out := filepath.Join(dir, fmt.Sprintf("out%d.go", i))
+		if err := RenderEnsureNewline(tt.tmpl, out, 0644, "main"); err != nil {
+			t.Fatalf("test %d: failed to render: %v", i, err)
+		}
+		if have, _ := ioutil.ReadFile(out); string(have) != tt.want {
+			t.Errorf("test %d: output mismatch: have %q, want %q", i, have, tt.want)
+		}
+	}
+}
+
+// Tests that the seq counter yields successive numbers and restarts per set.
+func TestSeqFuncs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "build-render-")
+	if err != nil {
+		t.Fatalf("failed to create temporary dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tmpl := "{{range .}}{{.}} = {{seq}}\n{{end}}"
+	items := []string{"Frontier", "Homestead", "Byzantium"}
+	for i := 0; i < 2; i++ {
+		out := filepath.Join(dir, fmt.Sprintf("enum%d.txt", i))
+		if err := RenderWithFuncs(tmpl, out, 0644, SeqFuncs(10), items); err != nil {
+			t.Fatalf("render %d: failed: %v", i, err)
+		}
+		want := "Frontier = 10\nHomestead = 11\nByzantium = 12\n"
+		if have, _ := ioutil.ReadFile(out); string(have) != want {
+			t.Errorf("render %d: output mismatch: have %q, want %q", i, have, want)
+		}
+	}
+}
+
+// Tests that batches of templates are rendered concurrently and correctly.
+func TestRenderBatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "build-render-")
+	if err != nil {
+		t.Fatalf("failed to create temporary dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var jobs []RenderJob
+	for i := 0; i < 100; i++ {
+		jobs = append(jobs, RenderJob{
+			Template: "file {{.}}\n",
+			Output:   filepath.Join(dir, fmt.Sprintf("%d", i%10), fmt.Sprintf("file%d.txt", i)),
+			Data:     i,
+			Perm:     0644,
+		})
+	}
+	if err := RenderBatch(jobs, 8); err != nil {
+		t.Fatalf("failed to render batch: %v", err)
+	}
+	for i, job := range jobs {
+		want := fmt.Sprintf("file %d\n", i)
+		if have, err := ioutil.ReadFile(job.Output); err != nil || string(have) != want {
+			t.Errorf("job %d: output mismatch: have %q, %v, want %q", i, have, err, want)
+		}
+	}
+	// Failures of individual jobs must all be reported
+	jobs = []RenderJob{
+		{Template: "{{", Output: filepath.Join(dir, "broken.txt"), Perm: 0644},
+		{Template: "ok", Output: filepath.Join(dir, "ok.txt"), Perm: 0644},
+		{Template: "dup", Output: jobs[0].Output, Perm: 0644},
+	}
+	err = RenderBatch(jobs, 2)
+	if err == nil {
+		t.Fatalf("failing batch succeeded")
+	}
+	if !strings.Contains(err.Error(), "2 of 3 renders failed") {
+		t.Errorf("failure count mismatch: %v", err)
+	}
+}
+
+// Tests that repeatedly rendered templates are only parsed once, while still
+// binding the function values of every individual render.
+func TestTemplateCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "build-render-")
+	if err != nil {
+		t.Fatalf("failed to create temporary dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ClearTemplateCache()
+	defer ClearTemplateCache()
+
+	parses := 0
+	defer func(parse func(*template.Template, string) (*template.Template, error)) { parseTemplate = parse }(parseTemplate)
+	parseTemplate = func(tpl *template.Template, content string) (*template.Template, error) {
+		parses++
+		return tpl.Parse(content)
+	}
+	for i := 0; i < 10; i++ {
+		out := filepath.Join(dir, fmt.Sprintf("seq-%d.txt", i))
+		if err := RenderWithFuncs("{{seq}} {{seq}} {{upper .}}", out, 0644, SeqFuncs(0), "x"); err != nil {
+			t.Fatalf("render %d: failed: %v", i, err)
+		}
+		if have, _ := ioutil.ReadFile(out); string(have) != "0 1 X" {
+			t.Errorf("render %d: output mismatch: have %q, want %q", i, have, "0 1 X")
+		}
+	}
+	if parses != 1 {
+		t.Errorf("parse count mismatch: have %d, want 1", parses)
+	}
+	// Different delimiters must not reuse the cached parse
+	if err := RenderWithDelims("[[", "]]", "{{seq}} [[.]]", filepath.Join(dir, "delims.txt"), 0644, "x"); err != nil {
+		t.Fatalf("failed to render with delimiters: %v", err)
+	}
+	if parses != 2 {
+		t.Errorf("parse count mismatch after delimiter change: have %d, want 2", parses)
+	}
+}
+
+// Benchmarks rendering the same template repeatedly through the cache.
+func BenchmarkRenderCached(b *testing.B) {
+	dir, err := ioutil.TempDir("", "build-render-")
+	if err != nil {
+		b.Fatalf("failed to create temporary dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	items := make([]interface{}, b.N)
+	for i := range items {
+		items[i] = i
+	}
+	b.ResetTimer()
+	err = RenderEach("package gen\n\nconst N = {{.}}\n", items, func(x interface{}) string {
+		return filepath.Join(dir, fmt.Sprintf("gen%d.go", x))
+	}, 0644)
+	if err != nil {
+		b.Fatalf("failed to render: %v", err)
+	}
+}
+
+// Tests that output containing invalid UTF-8 is rejected without being written.
+func TestRenderUTF8(t *testing.T) {
+	dir, err := ioutil.TempDir("", "build-render-")
+	if err != nil {
+		t.Fatalf("failed to create temporary dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, "valid.txt")
+	if err := RenderUTF8("Ðapp: {{.}}", out, 0644, "ħello"); err != nil {
+		t.Fatalf("valid UTF-8 rejected: %v", err)
+	}
+	out = filepath.Join(dir, "invalid.txt")
+	err = RenderUTF8("Ðapp: {{.}}", out, 0644, "ab\xffcd")
+	if err == nil {
+		t.Fatalf("invalid UTF-8 accepted")
+	}
+	if !strings.Contains(err.Error(), "offset 9") {
+		t.Errorf("error missing invalid byte offset: %v", err)
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("invalid output was written")
+	}
+}
+
+// Tests that unused imports are removed from generated Go code.
+func TestRenderGoImports(t *testing.T) {
+	dir, err := ioutil.TempDir("", "build-render-")
+	if err != nil {
+		t.Fatalf("failed to create temporary dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tmpl := `package {{.Package}}
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+func Greet() string {
+	return fmt.Sprintf("hello %s", {{printf "%q" .Name}})
+}
+`
+	out := filepath.Join(dir, "greet.go")
+	if err := RenderGoImports(tmpl, out, 0644, map[string]string{"Package": "greet", "Name": "geth"}); err != nil {
+		t.Fatalf("failed to render Go source: %v", err)
+	}
+	code := mustReadFile(t, out)
+	if !strings.Contains(code, `"fmt"`) {
+		t.Errorf("used import missing:\n%s", code)
+	}
+	if strings.Contains(code, `"os"`) || strings.Contains(code, `"strings"`) {
+		t.Errorf("unused imports not removed:\n%s", code)
+	}
+	// Invalid source must not be written
+	out = filepath.Join(dir, "broken.go")
+	if err := RenderGoImports("package {{.}}\n\nfunc {", out, 0644, "broken"); err == nil {
+		t.Errorf("invalid Go source accepted")
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("invalid Go source written")
+	}
+}
+
+// Tests that JSON output is only written if it conforms to the schema.
+func TestRenderValidatedJSON(t *testing.T) {
+	dir, err := ioutil.TempDir("", "build-render-")
+	if err != nil {
+		t.Fatalf("failed to create temporary dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	schema := filepath.Join(dir, "genesis.schema.json")
+	if err := ioutil.WriteFile(schema, []byte(`{
+	"type": "object",
+	"required": ["chainId", "network"],
+	"properties": {
+		"chainId": {"type": "integer", "minimum": 1},
+		"network": {"type": "string", "enum": ["mainnet", "ropsten", "rinkeby"]}
+	}
+}`), 0644); err != nil {
+		t.Fatalf("failed to write schema: %v", err)
+	}
+	tmpl := `{"chainId": {{.ChainID}}, "network": "{{.Network}}"}`
+
+	out := filepath.Join(dir, "genesis.json")
+	if err := RenderValidatedJSON(tmpl, out, schema, 0644, map[string]interface{}{"ChainID": 4, "Network": "rinkeby"}); err != nil {
+		t.Fatalf("failed to render valid config: %v", err)
+	}
+	if have, want := mustReadFile(t, out), `{"chainId": 4, "network": "rinkeby"}`; have != want {
+		t.Errorf("output mismatch: have %s, want %s", have, want)
+	}
+	invalid := filepath.Join(dir, "invalid.json")
+	err = RenderValidatedJSON(tmpl, invalid, schema, 0644, map[string]interface{}{"ChainID": 0, "Network": "olympic"})
+	if err == nil {
+		t.Fatalf("schema violation not reported")
+	}
+	for _, want := range []string{"/chainId: value 0 below minimum 1", "/network: value olympic not in enum"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error missing %q: %v", want, err)
+		}
+	}
+	if _, err := os.Stat(invalid); !os.IsNotExist(err) {
+		t.Errorf("invalid output written")
+	}
+	if err := RenderValidatedJSON(`{"chainId": {{.}}`, invalid, schema, 0644, 1); err == nil || !strings.Contains(err.Error(), "not valid JSON") {
+		t.Errorf("malformed JSON error mismatch: %v", err)
+	}
+	// Schemas the validator can't fully enforce must be rejected
+	unsupported := filepath.Join(dir, "unsupported.schema.json")
+	if err := ioutil.WriteFile(unsupported, []byte(`{"oneOf": [{"type": "integer"}]}`), 0644); err != nil {
+		t.Fatalf("failed to write schema: %v", err)
+	}
+	if err := RenderValidatedJSON(`"rinkeby"`, invalid, unsupported, 0644, nil); err == nil || !strings.Contains(err.Error(), `unsupported schema keyword "oneOf"`) {
+		t.Errorf("unsupported schema error mismatch: %v", err)
+	}
+	if _, err := os.Stat(invalid); !os.IsNotExist(err) {
+		t.Errorf("output written for unsupported schema")
+	}
+}
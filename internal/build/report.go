@@ -0,0 +1,94 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// CommandRecord describes a single command executed during a build.
+type CommandRecord struct {
+	Args     []string      `json:"args"`
+	Env      []string      `json:"env,omitempty"` // Variables differing from the parent environment
+	Dir      string        `json:"dir,omitempty"`
+	Duration time.Duration `json:"duration"` // Wall-clock run time in nanoseconds
+	ExitCode int           `json:"exitCode"`
+}
+
+// BuildReport accumulates records of the commands run by a build, providing an
+// auditable trace of everything that was executed. It is safe for concurrent use.
+type BuildReport struct {
+	lock     sync.Mutex
+	Commands []CommandRecord `json:"commands"`
+}
+
+// RunTimed executes the command like the package level RunTimed and appends a
+// record of the run to the report.
+func (r *BuildReport) RunTimed(cmd *exec.Cmd) (time.Duration, int, error) {
+	duration, code, err := RunTimed(cmd)
+	r.Record(cmd, duration, code)
+	return duration, code, err
+}
+
+// Record appends a record of an already executed command to the report.
+func (r *BuildReport) Record(cmd *exec.Cmd, duration time.Duration, exitCode int) {
+	record := CommandRecord{
+		Args:     append([]string(nil), cmd.Args...),
+		Env:      envOverrides(cmd.Env),
+		Dir:      cmd.Dir,
+		Duration: duration,
+		ExitCode: exitCode,
+	}
+	r.lock.Lock()
+	r.Commands = append(r.Commands, record)
+	r.lock.Unlock()
+}
+
+// WriteReport serializes the collected command records as JSON into path.
+func (r *BuildReport) WriteReport(path string) error {
+	r.lock.Lock()
+	blob, err := json.MarshalIndent(r, "", "  ")
+	r.lock.Unlock()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(blob, '\n'), 0644)
+}
+
+// envOverrides returns the entries of a command environment which aren't present
+// verbatim in the environment of the current process.
+func envOverrides(env []string) []string {
+	if env == nil {
+		return nil
+	}
+	parent := make(map[string]bool)
+	for _, kv := range os.Environ() {
+		parent[kv] = true
+	}
+	var overrides []string
+	for _, kv := range env {
+		if !parent[kv] {
+			overrides = append(overrides, kv)
+		}
+	}
+	return overrides
+}
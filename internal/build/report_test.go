@@ -0,0 +1,74 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Tests that commands run through a report are recorded and serialized.
+func TestBuildReport(t *testing.T) {
+	skipNoShell(t)
+
+	dir, err := ioutil.TempDir("", "build-report-")
+	if err != nil {
+		t.Fatalf("failed to create temporary dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	report := new(BuildReport)
+	report.RunTimed(exec.Command("true"))
+
+	failing := exec.Command("sh", "-c", "sleep 0.05; exit 3")
+	failing.Env = append(os.Environ(), "BUILD_REPORT_TEST=1")
+	report.RunTimed(failing)
+
+	path := filepath.Join(dir, "report.json")
+	if err := report.WriteReport(path); err != nil {
+		t.Fatalf("failed to write report: %v", err)
+	}
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read report: %v", err)
+	}
+	var written BuildReport
+	if err := json.Unmarshal(blob, &written); err != nil {
+		t.Fatalf("failed to decode report: %v", err)
+	}
+	if len(written.Commands) != 2 {
+		t.Fatalf("record count mismatch: have %d, want %d", len(written.Commands), 2)
+	}
+	if have := written.Commands[0]; have.Args[0] != "true" || have.ExitCode != 0 {
+		t.Errorf("first record mismatch: %+v", have)
+	}
+	have := written.Commands[1]
+	if have.ExitCode != 3 {
+		t.Errorf("exit code mismatch: have %d, want %d", have.ExitCode, 3)
+	}
+	if have.Duration <= 0 {
+		t.Errorf("duration not recorded: %v", have.Duration)
+	}
+	if want := []string{"BUILD_REPORT_TEST=1"}; !reflect.DeepEqual(have.Env, want) {
+		t.Errorf("env overrides mismatch: have %v, want %v", have.Env, want)
+	}
+}
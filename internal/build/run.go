@@ -0,0 +1,920 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// runCommand executes the given command with its output connected to the console,
+// like MustRun, but returns any failure to the caller instead of exiting.
+func runCommand(cmd *exec.Cmd) error {
+	fmt.Println(">>>", strings.Join(cmd.Args, " "))
+	if *DryRunFlag {
+		return nil
+	}
+	cmd.Stderr = os.Stderr
+	cmd.Stdout = os.Stdout
+	return runAbortable(cmd)
+}
+
+// tailBuffer is an io.Writer retaining only the last few complete lines written
+// into it, plus any trailing partial line.
+type tailBuffer struct {
+	lock    sync.Mutex
+	max     int
+	lines   []string
+	partial []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	data := append(b.partial, p...)
+	for {
+		idx := bytes.IndexByte(data, '\n')
+		if idx < 0 {
+			break
+		}
+		b.lines = append(b.lines, string(data[:idx]))
+		if len(b.lines) > b.max {
+			b.lines = b.lines[len(b.lines)-b.max:]
+		}
+		data = data[idx+1:]
+	}
+	b.partial = append([]byte(nil), data...)
+	return len(p), nil
+}
+
+// String returns the retained lines, including a trailing unterminated one.
+func (b *tailBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	lines := b.lines
+	if len(b.partial) > 0 {
+		lines = append(append([]string(nil), lines...), string(b.partial))
+		if len(lines) > b.max {
+			lines = lines[len(lines)-b.max:]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// RunTailBuffer executes the given command, streaming its output to the console
+// while retaining only the last maxLines lines of combined stdout and stderr in
+// memory. The retained lines are returned alongside any execution error, which
+// is useful for reporting the relevant tail of a long failing build.
+func RunTailBuffer(cmd *exec.Cmd, maxLines int) (string, error) {
+	if maxLines < 0 {
+		return "", fmt.Errorf("invalid tail length %d", maxLines)
+	}
+	fmt.Println(">>>", strings.Join(cmd.Args, " "))
+	if *DryRunFlag {
+		return "", nil
+	}
+	tail := &tailBuffer{max: maxLines}
+	cmd.Stdout = io.MultiWriter(os.Stdout, tail)
+	cmd.Stderr = io.MultiWriter(os.Stderr, tail)
+	err := runAbortable(cmd)
+	return tail.String(), err
+}
+
+// exitCode extracts the exit status of a finished command from its error. It
+// returns 0 on success and -1 if the command did not run to completion.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	if exit, ok := err.(*exec.ExitError); ok {
+		if status, ok := exit.Sys().(interface {
+			ExitStatus() int
+		}); ok {
+			return status.ExitStatus()
+		}
+	}
+	return -1
+}
+
+// RunExitCode executes the given command with its output connected to the
+// console and returns its exit code, which is -1 if the command could not be
+// run to completion. A non-zero exit is also reported via the returned error.
+func RunExitCode(cmd *exec.Cmd) (int, error) {
+	err := runCommand(cmd)
+	return exitCode(err), err
+}
+
+// cloneCommand returns an unstarted copy of cmd, allowing it to be run again.
+// Standard input is not carried over, since it can't generally be replayed.
+func cloneCommand(cmd *exec.Cmd) *exec.Cmd {
+	clone := exec.Command(cmd.Path, cmd.Args[1:]...)
+	clone.Args[0] = cmd.Args[0]
+	clone.Env = cmd.Env
+	clone.Dir = cmd.Dir
+	clone.Stdout = cmd.Stdout
+	clone.Stderr = cmd.Stderr
+	clone.ExtraFiles = cmd.ExtraFiles
+	clone.SysProcAttr = cmd.SysProcAttr
+	return clone
+}
+
+// RunRetryCodes executes the given command up to attempts times, retrying only
+// while it fails with one of the retryable exit codes. The wait between attempts
+// starts at backoff and doubles after every retry. Any other failure is returned
+// immediately.
+func RunRetryCodes(cmd *exec.Cmd, attempts int, backoff time.Duration, retryable []int) error {
+	for attempt := 1; ; attempt++ {
+		code, err := RunExitCode(cloneCommand(cmd))
+		if err == nil {
+			return nil
+		}
+		retry := false
+		for _, c := range retryable {
+			retry = retry || c == code
+		}
+		if !retry || attempt >= attempts {
+			return fmt.Errorf("%s failed after %d attempt(s): %v", cmd.Args[0], attempt, err)
+		}
+		log.Printf("Warning: %s exited with %d, retrying in %v", cmd.Args[0], code, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-AbortContext().Done():
+			return AbortContext().Err()
+		}
+		backoff *= 2
+	}
+}
+
+// RunTimed executes the given command with its output connected to the console
+// and returns the wall-clock time it took along with its exit code. A non-zero
+// exit is also reported via the returned error. In dry run mode the command is
+// not executed and zero values are returned.
+func RunTimed(cmd *exec.Cmd) (time.Duration, int, error) {
+	start := time.Now()
+	err := runCommand(cmd)
+	if *DryRunFlag {
+		return 0, 0, nil
+	}
+	return time.Since(start), exitCode(err), err
+}
+
+// MustRunToFile executes the given command with its standard output redirected
+// into outputPath (creating parent directories as needed) and exits the host
+// process for any error. Standard error is left connected to the console and a
+// partially written output file is removed on failure.
+func MustRunToFile(outputPath string, cmd *exec.Cmd) {
+	fmt.Println(">>>", strings.Join(cmd.Args, " "), ">", outputPath)
+	if *DryRunFlag {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		log.Fatal(err)
+	}
+	out, err := os.Create(outputPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cmd.Stdout = out
+	cmd.Stderr = os.Stderr
+	if err = runAbortable(cmd); err == nil {
+		err = out.Close()
+	} else {
+		out.Close()
+	}
+	if err != nil {
+		os.Remove(outputPath)
+		log.Fatal(err)
+	}
+}
+
+// RunJSON executes the given command and decodes its standard output as a single
+// JSON value into v. Standard error is left connected to the console.
+func RunJSON(cmd *exec.Cmd, v interface{}) error {
+	fmt.Println(">>>", strings.Join(cmd.Args, " "))
+	if *DryRunFlag {
+		return nil
+	}
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := runAbortable(cmd); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(stdout.Bytes(), v); err != nil {
+		return fmt.Errorf("failed to decode output of %s: %v", cmd.Args[0], err)
+	}
+	return nil
+}
+
+// RunJSONStream executes the given command and invokes handler for every JSON
+// value in its output stream (e.g. go test -json). If decoding or the handler
+// fails, the command is killed and the error returned.
+func RunJSONStream(cmd *exec.Cmd, handler func(json.RawMessage) error) error {
+	fmt.Println(">>>", strings.Join(cmd.Args, " "))
+	if *DryRunFlag {
+		return nil
+	}
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	wait, err := startAbortable(cmd)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(stdout)
+	for {
+		var msg json.RawMessage
+		if err = dec.Decode(&msg); err == io.EOF {
+			break
+		} else if err != nil {
+			err = fmt.Errorf("failed to decode output of %s: %v", cmd.Args[0], err)
+			break
+		}
+		if err = handler(msg); err != nil {
+			break
+		}
+	}
+	if err != nil && err != io.EOF {
+		cmd.Process.Kill()
+		wait()
+		return err
+	}
+	return wait()
+}
+
+// MustRunCleanEnv executes the given command like MustRun, but instead of the
+// full parent environment the child only receives the allowlisted variables
+// (if set in the parent) plus the extra variables, which take precedence.
+func MustRunCleanEnv(allow []string, extra map[string]string, cmd *exec.Cmd) {
+	allowed := make(map[string]bool)
+	for _, key := range allow {
+		allowed[key] = true
+	}
+	cmd.Env = []string{}
+	for _, kv := range os.Environ() {
+		key := strings.SplitN(kv, "=", 2)[0]
+		if _, override := extra[key]; allowed[key] && !override {
+			cmd.Env = append(cmd.Env, kv)
+		}
+	}
+	keys := make([]string, 0, len(extra))
+	for key := range extra {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		cmd.Env = append(cmd.Env, key+"="+extra[key])
+	}
+	MustRun(cmd)
+}
+
+// backgroundStopTimeout is the time StopBackground waits for a process to exit
+// after interrupting it, before killing it forcefully.
+var backgroundStopTimeout = 5 * time.Second
+
+// StartBackground starts the given command without waiting for it to complete,
+// returning it so it can later be terminated via StopBackground. Output is
+// connected to the console. In dry run mode the command is returned unstarted.
+func StartBackground(cmd *exec.Cmd) (*exec.Cmd, error) {
+	fmt.Println(">>>", strings.Join(cmd.Args, " "), "&")
+	if *DryRunFlag {
+		return cmd, nil
+	}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return cmd, nil
+}
+
+// StopBackground terminates a command started by StartBackground. The process
+// is interrupted first and killed if it doesn't exit in time. Commands which
+// were never started are ignored.
+func StopBackground(cmd *exec.Cmd) {
+	if cmd == nil || cmd.Process == nil {
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(done)
+	}()
+	// Interrupts aren't supported on Windows, in which case we kill right away
+	if err := cmd.Process.Signal(os.Interrupt); err != nil {
+		cmd.Process.Kill()
+	}
+	select {
+	case <-done:
+	case <-time.After(backgroundStopTimeout):
+		cmd.Process.Kill()
+		<-done
+	}
+}
+
+// lineWriter is an io.Writer splitting the written data into lines, invoking a
+// callback for each complete one (without the trailing newline). Any remaining
+// partial line is delivered on Flush.
+type lineWriter struct {
+	lock    sync.Mutex
+	partial []byte
+	emit    func(line string)
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	data := append(w.partial, p...)
+	for {
+		idx := bytes.IndexByte(data, '\n')
+		if idx < 0 {
+			break
+		}
+		w.emit(string(data[:idx]))
+		data = data[idx+1:]
+	}
+	w.partial = append([]byte(nil), data...)
+	return len(p), nil
+}
+
+// Flush delivers any buffered unterminated line.
+func (w *lineWriter) Flush() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if len(w.partial) > 0 {
+		w.emit(string(w.partial))
+		w.partial = nil
+	}
+}
+
+// useColor reports whether ANSI colors should be emitted onto the given file,
+// which is the case for terminals unless NO_COLOR is set.
+func useColor(f *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// MustRunColored executes the given command like MustRun, but when running in a
+// terminal the lines written to standard error are highlighted in red to make
+// them stand out between the regular output.
+func MustRunColored(cmd *exec.Cmd) {
+	fmt.Println(">>>", strings.Join(cmd.Args, " "))
+	if *DryRunFlag {
+		return
+	}
+	if err := runColored(cmd, os.Stdout, os.Stderr, useColor(os.Stdout)); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// runColored executes cmd, forwarding its output into the given writers with
+// the standard error lines optionally wrapped in red ANSI escape sequences.
+func runColored(cmd *exec.Cmd, stdout, stderr io.Writer, color bool) error {
+	cmd.Stdout = stdout
+	if !color {
+		cmd.Stderr = stderr
+		return runAbortable(cmd)
+	}
+	colored := &lineWriter{emit: func(line string) {
+		fmt.Fprintf(stderr, "\x1b[31m%s\x1b[0m\n", line)
+	}}
+	cmd.Stderr = colored
+	err := runAbortable(cmd)
+	colored.Flush()
+	return err
+}
+
+// MustRunTraced executes the given command like MustRun, but wrapped in the given
+// system call tracer (e.g. strace on Linux, dtruss on macOS). If the tracer is
+// not installed or the platform is unsupported, a warning is printed and the
+// command runs untraced.
+func MustRunTraced(cmd *exec.Cmd, tracer string) {
+	if err := traceCommand(cmd, tracer); err != nil {
+		log.Printf("Warning: running %s untraced: %v", cmd.Args[0], err)
+	}
+	MustRun(cmd)
+}
+
+// traceCommand rewrites cmd in place to run under the given tracer.
+func traceCommand(cmd *exec.Cmd, tracer string) error {
+	if runtime.GOOS == "windows" {
+		return fmt.Errorf("tracing not supported on %s", runtime.GOOS)
+	}
+	path, err := exec.LookPath(tracer)
+	if err != nil {
+		return err
+	}
+	cmd.Args = append([]string{path, cmd.Path}, cmd.Args[1:]...)
+	cmd.Path = path
+	return nil
+}
+
+// MustRunWarnSlow executes the given command like MustRun and prints a warning
+// if it took longer than threshold. Slow runs do not fail the build.
+func MustRunWarnSlow(cmd *exec.Cmd, threshold time.Duration) {
+	start := time.Now()
+	MustRun(cmd)
+	if elapsed := time.Since(start); elapsed > threshold && !*DryRunFlag {
+		log.Printf("Warning: %s took %v, expected at most %v", strings.Join(cmd.Args, " "), elapsed, threshold)
+	}
+}
+
+// MustRunFiltered executes the given command like MustRun, but passes each line
+// of its standard output and error through transform before forwarding it to
+// the console. The transform may rewrite the line, or drop it by returning false.
+func MustRunFiltered(cmd *exec.Cmd, transform func(line string) (string, bool)) {
+	fmt.Println(">>>", strings.Join(cmd.Args, " "))
+	if *DryRunFlag {
+		return
+	}
+	if err := runFiltered(cmd, os.Stdout, os.Stderr, transform); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// runFiltered executes cmd, forwarding its transformed output lines into the
+// given writers.
+func runFiltered(cmd *exec.Cmd, stdout, stderr io.Writer, transform func(line string) (string, bool)) error {
+	filter := func(w io.Writer) *lineWriter {
+		return &lineWriter{emit: func(line string) {
+			if line, ok := transform(line); ok {
+				fmt.Fprintln(w, line)
+			}
+		}}
+	}
+	outw, errw := filter(stdout), filter(stderr)
+	cmd.Stdout, cmd.Stderr = outw, errw
+
+	err := runAbortable(cmd)
+	outw.Flush()
+	errw.Flush()
+	return err
+}
+
+// RunCached produces outputFile by executing cmd, storing a copy of the result
+// in cacheDir under the given key (e.g. a BuildID of the command inputs). If the
+// key is already cached, the command is skipped and the cached copy restored.
+func RunCached(cacheDir string, key string, cmd *exec.Cmd, outputFile string) error {
+	cached, err := SafeJoin(cacheDir, key)
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(cached); err == nil {
+		fmt.Println(">>> (cached)", strings.Join(cmd.Args, " "))
+		return copyFile(outputFile, cached, info.Mode().Perm())
+	}
+	if err := runCommand(cmd); err != nil {
+		return err
+	}
+	if *DryRunFlag {
+		return nil
+	}
+	info, err := os.Stat(outputFile)
+	if err != nil {
+		return fmt.Errorf("command didn't produce %s: %v", outputFile, err)
+	}
+	// Populate the cache atomically so interrupted runs don't leave junk behind
+	tmp := cached + ".tmp"
+	if err := copyFile(tmp, outputFile, info.Mode().Perm()); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, cached)
+}
+
+// RunNoStderr executes the given command with its standard output connected to
+// the console, treating any output on standard error as a failure even if the
+// command exits successfully. The captured standard error is included in the
+// returned error.
+func RunNoStderr(cmd *exec.Cmd) error {
+	fmt.Println(">>>", strings.Join(cmd.Args, " "))
+	if *DryRunFlag {
+		return nil
+	}
+	var stderr bytes.Buffer
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = &stderr
+	if err := runAbortable(cmd); err != nil {
+		return fmt.Errorf("%s failed: %v\n%s", cmd.Args[0], err, stderr.String())
+	}
+	if stderr.Len() > 0 {
+		return fmt.Errorf("%s wrote to stderr:\n%s", cmd.Args[0], stderr.String())
+	}
+	return nil
+}
+
+// syncWriter serializes writes to an underlying writer, allowing it to be shared
+// between the standard output and error streams of a process.
+type syncWriter struct {
+	lock sync.Mutex
+	w    io.Writer
+}
+
+func (w *syncWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.w.Write(p)
+}
+
+// RunWithWriter executes the given command, sending both its standard output and
+// standard error into w. Writes are serialized, so w needn't be safe for
+// concurrent use.
+func RunWithWriter(cmd *exec.Cmd, w io.Writer) error {
+	fmt.Println(">>>", strings.Join(cmd.Args, " "))
+	if *DryRunFlag {
+		return nil
+	}
+	sw := &syncWriter{w: w}
+	cmd.Stdout, cmd.Stderr = sw, sw
+	return runAbortable(cmd)
+}
+
+// errOutputCapped is returned by cappedWriter once its byte budget is exhausted.
+var errOutputCapped = errors.New("output cap exceeded")
+
+// outputCap tracks the output budget shared by the streams of a process.
+type outputCap struct {
+	lock     sync.Mutex
+	written  int64
+	limit    int64
+	exceeded bool
+	cmd      *exec.Cmd
+}
+
+// cappedWriter forwards output into w while the shared budget lasts, killing
+// the producing process once it is exhausted.
+type cappedWriter struct {
+	cap *outputCap
+	w   io.Writer
+}
+
+func (w *cappedWriter) Write(p []byte) (int, error) {
+	w.cap.lock.Lock()
+	defer w.cap.lock.Unlock()
+
+	if w.cap.exceeded {
+		return 0, errOutputCapped
+	}
+	if remaining := w.cap.limit - w.cap.written; int64(len(p)) > remaining {
+		w.w.Write(p[:remaining])
+		w.cap.written = w.cap.limit
+		w.cap.exceeded = true
+		w.cap.cmd.Process.Kill()
+		return int(remaining), errOutputCapped
+	}
+	w.cap.written += int64(len(p))
+	return w.w.Write(p)
+}
+
+// RunCappedOutput executes the given command with its output connected to the
+// console, killing it and returning an error as soon as standard output and
+// error together exceed maxBytes.
+func RunCappedOutput(cmd *exec.Cmd, maxBytes int64) error {
+	if maxBytes < 0 {
+		return fmt.Errorf("invalid output cap %d", maxBytes)
+	}
+	fmt.Println(">>>", strings.Join(cmd.Args, " "))
+	if *DryRunFlag {
+		return nil
+	}
+	limit := &outputCap{limit: maxBytes, cmd: cmd}
+	cmd.Stdout = &cappedWriter{limit, os.Stdout}
+	cmd.Stderr = &cappedWriter{limit, os.Stderr}
+	err := runAbortable(cmd)
+
+	limit.lock.Lock()
+	defer limit.lock.Unlock()
+	if limit.exceeded {
+		return fmt.Errorf("%s killed: output exceeded %d bytes", cmd.Args[0], maxBytes)
+	}
+	return err
+}
+
+// groupMarkers returns the log lines opening and closing a collapsible section
+// with the given title on the current CI service. Locally, and on services not
+// supporting sections, both are empty.
+func groupMarkers(title string) (string, string) {
+	switch CIProvider() {
+	case "github":
+		return "::group::" + title, "::endgroup::"
+	case "gitlab":
+		id, now := sectionID(title), time.Now().Unix()
+		return fmt.Sprintf("\x1b[0Ksection_start:%d:%s[collapsed=true]\r\x1b[0K%s", now, id, title),
+			fmt.Sprintf("\x1b[0Ksection_end:%d:%s\r\x1b[0K", now, id)
+	case "travis":
+		id := sectionID(title)
+		return "travis_fold:start:" + id + "\n" + title, "travis_fold:end:" + id
+	default:
+		return "", ""
+	}
+}
+
+// sectionID converts a title into an identifier usable in CI section markers.
+func sectionID(title string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		default:
+			return '_'
+		}
+	}, title)
+}
+
+// MustRunGrouped executes the given command like MustRun, folding its output
+// into a collapsible section with the given title if the CI service supports it.
+// The section is closed before exiting on failure, so the failing step's output
+// isn't swallowed by an unterminated group.
+func MustRunGrouped(title string, cmd *exec.Cmd) {
+	if err := runGrouped(os.Stdout, title, cmd); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// runGrouped executes cmd with its standard output and the section markers
+// going into w, returning any failure after the section was closed.
+func runGrouped(w io.Writer, title string, cmd *exec.Cmd) error {
+	start, end := groupMarkers(title)
+	if start != "" {
+		fmt.Fprintln(w, start)
+	}
+	fmt.Fprintln(w, ">>>", strings.Join(cmd.Args, " "))
+	for _, line := range commandDetails(cmd) {
+		fmt.Fprintln(w, "   ", line)
+	}
+	var err error
+	if !*DryRunFlag {
+		cmd.Stdout, cmd.Stderr = w, os.Stderr
+		err = runAbortable(cmd)
+	}
+	if start != "" {
+		fmt.Fprintln(w, end)
+	}
+	return err
+}
+
+// RunPipeline executes the given commands as a shell style pipeline, connecting
+// the standard output of every command to the standard input of the next one.
+// The last command's output and all error streams go to the console. All stages
+// run concurrently. Like in a shell, upstream stages killed by a broken pipe are
+// not considered failures; otherwise the error of the first failing stage (or
+// of the stage that couldn't be started) is returned.
+func RunPipeline(cmds ...*exec.Cmd) error {
+	if len(cmds) == 0 {
+		return nil
+	}
+	stages := make([]string, len(cmds))
+	for i, cmd := range cmds {
+		stages[i] = strings.Join(cmd.Args, " ")
+	}
+	fmt.Println(">>>", strings.Join(stages, " | "))
+	if *DryRunFlag {
+		return nil
+	}
+	ctx := AbortContext()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	// Wire up the stages, the pipe ends are inherited by the children
+	var ends []*os.File
+	defer func() {
+		for _, end := range ends {
+			end.Close()
+		}
+	}()
+	for i, cmd := range cmds {
+		cmd.Stderr = os.Stderr
+		if i == len(cmds)-1 {
+			cmd.Stdout = os.Stdout
+			break
+		}
+		r, w, err := os.Pipe()
+		if err != nil {
+			return err
+		}
+		ends = append(ends, r, w)
+		cmd.Stdout, cmds[i+1].Stdin = w, r
+	}
+	var (
+		errs     = make([]error, len(cmds))
+		started  = 0
+		startErr error
+	)
+	for ; started < len(cmds); started++ {
+		if err := cmds[started].Start(); err != nil {
+			startErr = fmt.Errorf("pipeline stage %d (%s) failed to start: %v", started+1, stages[started], err)
+			for _, cmd := range cmds[:started] {
+				cmd.Process.Kill()
+			}
+			break
+		}
+	}
+	// Close our copies of the pipes so stages see EOF when their input exits
+	for _, end := range ends {
+		end.Close()
+	}
+	ends = nil
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			for _, cmd := range cmds[:started] {
+				cmd.Process.Kill()
+			}
+		case <-done:
+		}
+	}()
+	for i, cmd := range cmds[:started] {
+		errs[i] = cmd.Wait()
+	}
+	close(done)
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if startErr != nil {
+		return startErr
+	}
+	for i, err := range errs {
+		// Upstream stages dying of SIGPIPE just mean a later one stopped reading
+		if err != nil && (i == len(cmds)-1 || !brokenPipe(err)) {
+			return fmt.Errorf("pipeline stage %d (%s) failed: %v", i+1, stages[i], err)
+		}
+	}
+	return nil
+}
+
+// MustRunWithPath executes the given command like MustRun, prepending the extra
+// directories to its PATH. If the command was given by name only, it is looked
+// up in the extra directories first, so project local tools take precedence.
+func MustRunWithPath(extraPaths []string, cmd *exec.Cmd) {
+	dirs := make([]string, len(extraPaths))
+	for i, dir := range extraPaths {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		dirs[i] = abs
+	}
+	if name := cmd.Args[0]; !strings.ContainsAny(name, `/\`) {
+		for _, dir := range dirs {
+			if path, err := exec.LookPath(filepath.Join(dir, name)); err == nil {
+				cmd.Path, cmd.Err = path, nil
+				break
+			}
+		}
+	}
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	path := strings.Join(dirs, string(os.PathListSeparator))
+	cmd.Env = nil
+	for _, kv := range env {
+		if kv := strings.SplitN(kv, "=", 2); len(kv) == 2 && strings.EqualFold(kv[0], "PATH") {
+			if kv[1] != "" {
+				path += string(os.PathListSeparator) + kv[1]
+			}
+			continue
+		}
+		cmd.Env = append(cmd.Env, kv)
+	}
+	cmd.Env = append(cmd.Env, "PATH="+path)
+	MustRun(cmd)
+}
+
+// MustRunSplitLogs executes the given command with its output streamed to the
+// console, additionally saving its standard output into stdoutLog and standard
+// error into stderrLog (creating parent directories as needed). It exits the
+// host process for any error, keeping the logs for inspection.
+func MustRunSplitLogs(stdoutLog, stderrLog string, cmd *exec.Cmd) {
+	fmt.Println(">>>", strings.Join(cmd.Args, " "), ">", stdoutLog, "2>", stderrLog)
+	if *DryRunFlag {
+		return
+	}
+	create := func(path string) *os.File {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			log.Fatal(err)
+		}
+		f, err := os.Create(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return f
+	}
+	stdout, stderr := create(stdoutLog), create(stderrLog)
+	cmd.Stdout = io.MultiWriter(os.Stdout, stdout)
+	cmd.Stderr = io.MultiWriter(os.Stderr, stderr)
+
+	err := runAbortable(cmd)
+	for _, f := range []*os.File{stdout, stderr} {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// MustRunWithInputFile executes the given command with the contents of inputPath
+// fed into its standard input and exits the host process for any error. The
+// output is streamed to the console.
+func MustRunWithInputFile(inputPath string, cmd *exec.Cmd) {
+	fmt.Println(">>>", strings.Join(cmd.Args, " "), "<", inputPath)
+	if *DryRunFlag {
+		return
+	}
+	in, err := os.Open(inputPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer in.Close()
+
+	cmd.Stdin = in
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := runAbortable(cmd); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// TimedLine is a line of command output along with the time it was produced,
+// relative to the start of the command.
+type TimedLine struct {
+	Elapsed time.Duration
+	Text    string
+}
+
+// RunTimestamped executes the given command, forwarding its output to the
+// console and recording when each line of its standard output and error was
+// produced. A failure of the command is logged as a warning, the lines printed
+// up to that point are still returned.
+func RunTimestamped(cmd *exec.Cmd) []TimedLine {
+	fmt.Println(">>>", strings.Join(cmd.Args, " "))
+	if *DryRunFlag {
+		return nil
+	}
+	var (
+		lock  sync.Mutex
+		lines []TimedLine
+		start time.Time
+	)
+	record := func(w io.Writer) *lineWriter {
+		return &lineWriter{emit: func(line string) {
+			lock.Lock()
+			lines = append(lines, TimedLine{Elapsed: time.Since(start), Text: line})
+			lock.Unlock()
+			fmt.Fprintln(w, line)
+		}}
+	}
+	outw, errw := record(os.Stdout), record(os.Stderr)
+	cmd.Stdout, cmd.Stderr = outw, errw
+
+	start = time.Now()
+	err := runAbortable(cmd)
+	outw.Flush()
+	errw.Flush()
+	if err != nil {
+		log.Printf("Warning: %s failed: %v", cmd.Args[0], err)
+	}
+	return lines
+}
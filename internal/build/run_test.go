@@ -0,0 +1,747 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// skipNoShell skips tests which rely on a POSIX shell for scripting commands.
+func skipNoShell(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test requires a POSIX shell")
+	}
+}
+
+// writeStub creates an executable shell script with the given name and body in
+// dir, returning its path.
+func writeStub(t *testing.T, dir, name, script string) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatalf("failed to write %s stub: %v", name, err)
+	}
+	return path
+}
+
+// stubGo replaces the go tool with a shell script stub for the duration of a
+// test. The returned function restores the original tool and removes the stub.
+func stubGo(t *testing.T, script string) (string, func()) {
+	skipNoShell(t)
+
+	dir, err := ioutil.TempDir("", "build-stub-")
+	if err != nil {
+		t.Fatalf("failed to create stub dir: %v", err)
+	}
+	oldGo := goBinary
+	goBinary = writeStub(t, dir, "go", script)
+	return dir, func() {
+		goBinary = oldGo
+		os.RemoveAll(dir)
+	}
+}
+
+// Tests that only the final lines of a command's output are retained.
+func TestRunTailBuffer(t *testing.T) {
+	skipNoShell(t)
+
+	cmd := exec.Command("sh", "-c", "for i in 1 2 3 4 5 6 7 8 9 10; do echo line $i; done")
+	have, err := RunTailBuffer(cmd, 3)
+	if err != nil {
+		t.Fatalf("failed to run command: %v", err)
+	}
+	var want []string
+	for i := 8; i <= 10; i++ {
+		want = append(want, fmt.Sprintf("line %d", i))
+	}
+	if have != strings.Join(want, "\n") {
+		t.Errorf("tail mismatch: have %q, want %q", have, strings.Join(want, "\n"))
+	}
+	if have, err := RunTailBuffer(exec.Command("sh", "-c", "echo line"), 0); err != nil || have != "" {
+		t.Errorf("empty tail mismatch: have %q, %v", have, err)
+	}
+	neg := exec.Command("sh", "-c", "echo line")
+	if _, err := RunTailBuffer(neg, -1); err == nil {
+		t.Errorf("negative tail length accepted")
+	}
+	if neg.Process != nil {
+		t.Errorf("command started despite invalid tail length")
+	}
+}
+
+// Tests that the duration and exit code of a failing command are reported.
+func TestRunTimed(t *testing.T) {
+	skipNoShell(t)
+
+	duration, code, err := RunTimed(exec.Command("sh", "-c", "sleep 0.1; exit 1"))
+	if err == nil {
+		t.Errorf("failing command reported no error")
+	}
+	if code != 1 {
+		t.Errorf("exit code mismatch: have %d, want %d", code, 1)
+	}
+	if duration < 100*time.Millisecond || duration > 10*time.Second {
+		t.Errorf("implausible duration: %v", duration)
+	}
+}
+
+// Tests that a command's standard output is redirected into the requested file.
+func TestMustRunToFile(t *testing.T) {
+	skipNoShell(t)
+
+	dir, err := ioutil.TempDir("", "build-run-")
+	if err != nil {
+		t.Fatalf("failed to create temporary dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, "gen", "output.txt")
+	MustRunToFile(out, exec.Command("echo", "generated"))
+
+	have, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatalf("failed to read output file: %v", err)
+	}
+	if string(have) != "generated\n" {
+		t.Errorf("output mismatch: have %q, want %q", have, "generated\n")
+	}
+}
+
+// Tests that a command's JSON output can be decoded as a single object.
+func TestRunJSON(t *testing.T) {
+	skipNoShell(t)
+
+	var result struct {
+		Name    string
+		Imports []string
+	}
+	cmd := exec.Command("sh", "-c", `echo '{"Name": "build", "Imports": ["fmt", "os"]}'`)
+	if err := RunJSON(cmd, &result); err != nil {
+		t.Fatalf("failed to run command: %v", err)
+	}
+	if result.Name != "build" || len(result.Imports) != 2 {
+		t.Errorf("decoded result mismatch: have %+v", result)
+	}
+	if err := RunJSON(exec.Command("echo", "not json"), &result); err == nil {
+		t.Errorf("invalid JSON output decoded without error")
+	}
+}
+
+// Tests that every value of a newline delimited JSON stream is delivered.
+func TestRunJSONStream(t *testing.T) {
+	skipNoShell(t)
+
+	cmd := exec.Command("sh", "-c", `for i in 1 2 3; do echo "{\"Seq\": $i}"; done`)
+	var seqs []int
+	err := RunJSONStream(cmd, func(msg json.RawMessage) error {
+		var event struct{ Seq int }
+		if err := json.Unmarshal(msg, &event); err != nil {
+			return err
+		}
+		seqs = append(seqs, event.Seq)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to run command: %v", err)
+	}
+	if !reflect.DeepEqual(seqs, []int{1, 2, 3}) {
+		t.Errorf("stream mismatch: have %v, want %v", seqs, []int{1, 2, 3})
+	}
+}
+
+// Tests that children only see allowlisted and explicitly added variables.
+func TestMustRunCleanEnv(t *testing.T) {
+	skipNoShell(t)
+
+	dir, err := ioutil.TempDir("", "build-run-")
+	if err != nil {
+		t.Fatalf("failed to create temporary dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	os.Setenv("BUILD_ALLOWED", "yes")
+	os.Setenv("BUILD_LEAKED", "no")
+	defer os.Unsetenv("BUILD_ALLOWED")
+	defer os.Unsetenv("BUILD_LEAKED")
+
+	out := filepath.Join(dir, "env")
+	cmd := exec.Command("/bin/sh", "-c", "env > "+out)
+	MustRunCleanEnv([]string{"BUILD_ALLOWED", "BUILD_MISSING"}, map[string]string{"BUILD_EXTRA": "1"}, cmd)
+
+	blob, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatalf("failed to read child environment: %v", err)
+	}
+	env := string(blob)
+	for _, want := range []string{"BUILD_ALLOWED=yes", "BUILD_EXTRA=1"} {
+		if !strings.Contains(env, want) {
+			t.Errorf("child environment missing %s", want)
+		}
+	}
+	for _, leak := range []string{"BUILD_LEAKED", "BUILD_MISSING"} {
+		if strings.Contains(env, leak) {
+			t.Errorf("child environment contains %s", leak)
+		}
+	}
+}
+
+// Tests that background processes keep running until explicitly stopped.
+func TestStartBackground(t *testing.T) {
+	skipNoShell(t)
+
+	cmd, err := StartBackground(exec.Command("sleep", "30"))
+	if err != nil {
+		t.Fatalf("failed to start background process: %v", err)
+	}
+	if cmd.ProcessState != nil {
+		t.Fatalf("background process already finished: %v", cmd.ProcessState)
+	}
+	start := time.Now()
+	StopBackground(cmd)
+
+	if elapsed := time.Since(start); elapsed > backgroundStopTimeout {
+		t.Errorf("graceful stop took too long: %v", elapsed)
+	}
+	if cmd.ProcessState == nil {
+		t.Fatalf("background process not reaped")
+	}
+	if cmd.ProcessState.Success() {
+		t.Errorf("background process ran to completion instead of being stopped")
+	}
+}
+
+// Tests that standard error is only colorized when colors are enabled.
+func TestRunColored(t *testing.T) {
+	skipNoShell(t)
+
+	defer os.Setenv("NO_COLOR", os.Getenv("NO_COLOR"))
+	os.Setenv("NO_COLOR", "1")
+	if useColor(os.Stdout) {
+		t.Errorf("colors enabled despite NO_COLOR")
+	}
+	script := "echo out; echo err >&2"
+
+	var stdout, stderr bytes.Buffer
+	if err := runColored(exec.Command("sh", "-c", script), &stdout, &stderr, false); err != nil {
+		t.Fatalf("failed to run command: %v", err)
+	}
+	if stdout.String() != "out\n" || stderr.String() != "err\n" {
+		t.Errorf("plain output mismatch: stdout %q, stderr %q", stdout.String(), stderr.String())
+	}
+	stdout.Reset()
+	stderr.Reset()
+	if err := runColored(exec.Command("sh", "-c", script), &stdout, &stderr, true); err != nil {
+		t.Fatalf("failed to run command: %v", err)
+	}
+	if stdout.String() != "out\n" || stderr.String() != "\x1b[31merr\x1b[0m\n" {
+		t.Errorf("colored output mismatch: stdout %q, stderr %q", stdout.String(), stderr.String())
+	}
+}
+
+// Tests that commands are wrapped in the tracer if available and run plainly
+// otherwise.
+func TestMustRunTraced(t *testing.T) {
+	skipNoShell(t)
+
+	dir, err := ioutil.TempDir("", "build-trace-")
+	if err != nil {
+		t.Fatalf("failed to create temporary dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Missing tracer, command must still execute
+	marker := filepath.Join(dir, "plain")
+	MustRunTraced(exec.Command("touch", marker), filepath.Join(dir, "missing-strace"))
+	if _, err := os.Stat(marker); err != nil {
+		t.Errorf("untraced command not executed: %v", err)
+	}
+	// Stub tracer recording its arguments before running the command
+	tracer := writeStub(t, dir, "strace", `echo "$@" > "$(dirname "$0")/traced"; exec "$@"`)
+	marker = filepath.Join(dir, "traced-marker")
+	cmd := exec.Command("touch", marker)
+	MustRunTraced(cmd, tracer)
+
+	if cmd.Args[0] != tracer {
+		t.Errorf("tracer not prefixed: %v", cmd.Args)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Errorf("traced command not executed: %v", err)
+	}
+	if traced, err := ioutil.ReadFile(filepath.Join(dir, "traced")); err != nil || !strings.Contains(string(traced), marker) {
+		t.Errorf("tracer not invoked with command: %q, %v", traced, err)
+	}
+}
+
+// Tests that slow commands produce a warning without failing.
+func TestMustRunWarnSlow(t *testing.T) {
+	skipNoShell(t)
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	MustRunWarnSlow(exec.Command("true"), time.Minute)
+	if logs.Len() != 0 {
+		t.Errorf("warning emitted for fast command: %q", logs.String())
+	}
+	MustRunWarnSlow(exec.Command("sleep", "0.1"), 10*time.Millisecond)
+	if !strings.Contains(logs.String(), "Warning: sleep 0.1 took") {
+		t.Errorf("no warning emitted for slow command: %q", logs.String())
+	}
+}
+
+// Tests that lines split across writes are reassembled before being emitted.
+func TestLineWriter(t *testing.T) {
+	var lines []string
+	w := &lineWriter{emit: func(line string) { lines = append(lines, line) }}
+	for _, chunk := range []string{"fir", "st\nsec", "ond\n", "\nthi", "rd"} {
+		w.Write([]byte(chunk))
+	}
+	w.Flush()
+
+	if want := []string{"first", "second", "", "third"}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("lines mismatch: have %q, want %q", lines, want)
+	}
+}
+
+// Tests that output lines are rewritten or dropped on both streams.
+func TestRunFiltered(t *testing.T) {
+	skipNoShell(t)
+
+	script := `echo "keep /home/user/a"; echo "drop secret"; echo "keep /home/user/b" >&2; echo "secret" >&2; printf "tail"`
+	transform := func(line string) (string, bool) {
+		if strings.Contains(line, "secret") {
+			return "", false
+		}
+		return strings.Replace(line, "/home/user", "~", -1), true
+	}
+	var stdout, stderr bytes.Buffer
+	if err := runFiltered(exec.Command("sh", "-c", script), &stdout, &stderr, transform); err != nil {
+		t.Fatalf("failed to run command: %v", err)
+	}
+	if want := "keep ~/a\ntail\n"; stdout.String() != want {
+		t.Errorf("stdout mismatch: have %q, want %q", stdout.String(), want)
+	}
+	if want := "keep ~/b\n"; stderr.String() != want {
+		t.Errorf("stderr mismatch: have %q, want %q", stderr.String(), want)
+	}
+}
+
+// Tests that cached command outputs are restored without re-running.
+func TestRunCached(t *testing.T) {
+	skipNoShell(t)
+
+	dir, err := ioutil.TempDir("", "build-cache-")
+	if err != nil {
+		t.Fatalf("failed to create temporary dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		cache   = filepath.Join(dir, "cache")
+		output  = filepath.Join(dir, "out", "gen.go")
+		counter = filepath.Join(dir, "runs")
+		script  = "echo run >> " + counter + "; mkdir -p " + filepath.Dir(output) + "; echo generated > " + output
+	)
+	for i := 0; i < 2; i++ {
+		os.Remove(output)
+		if err := RunCached(cache, "abcdef", exec.Command("sh", "-c", script), output); err != nil {
+			t.Fatalf("run %d: failed: %v", i, err)
+		}
+		if have, _ := ioutil.ReadFile(output); string(have) != "generated\n" {
+			t.Errorf("run %d: output mismatch: have %q, want %q", i, have, "generated\n")
+		}
+	}
+	if runs, _ := ioutil.ReadFile(counter); string(runs) != "run\n" {
+		t.Errorf("command execution count mismatch: have %q, want a single run", runs)
+	}
+	if err := RunCached(cache, "../escape", exec.Command("true"), output); err == nil {
+		t.Errorf("cache key escaping the cache dir accepted")
+	}
+}
+
+// Tests that output on standard error fails an otherwise successful command.
+func TestRunNoStderr(t *testing.T) {
+	skipNoShell(t)
+
+	if err := RunNoStderr(exec.Command("sh", "-c", "echo fine")); err != nil {
+		t.Errorf("quiet command rejected: %v", err)
+	}
+	err := RunNoStderr(exec.Command("sh", "-c", "echo 'warning: deprecated flag' >&2; exit 0"))
+	if err == nil {
+		t.Fatalf("command writing to stderr accepted")
+	}
+	if !strings.Contains(err.Error(), "warning: deprecated flag") {
+		t.Errorf("error missing stderr output: %v", err)
+	}
+}
+
+// countingWriter is an io.Writer counting the bytes written into it.
+type countingWriter struct {
+	n int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += len(p)
+	return len(p), nil
+}
+
+// Tests that a custom writer receives both output streams of a command.
+func TestRunWithWriter(t *testing.T) {
+	skipNoShell(t)
+
+	var (
+		counter = new(countingWriter)
+		buffer  = new(bytes.Buffer)
+	)
+	cmd := exec.Command("sh", "-c", "echo stdout; echo stderr >&2; echo done")
+	if err := RunWithWriter(cmd, io.MultiWriter(counter, buffer)); err != nil {
+		t.Fatalf("failed to run command: %v", err)
+	}
+	if want := len("stdout\nstderr\ndone\n"); counter.n != want {
+		t.Errorf("byte count mismatch: have %d, want %d", counter.n, want)
+	}
+	for _, line := range []string{"stdout", "stderr", "done"} {
+		if !strings.Contains(buffer.String(), line) {
+			t.Errorf("output missing %q: %q", line, buffer.String())
+		}
+	}
+}
+
+// Tests that a command flooding its output is killed once the cap is reached.
+func TestRunCappedOutput(t *testing.T) {
+	skipNoShell(t)
+
+	if err := RunCappedOutput(exec.Command("sh", "-c", "echo short"), 1024); err != nil {
+		t.Errorf("command within cap rejected: %v", err)
+	}
+	neg := exec.Command("sh", "-c", "echo short")
+	if err := RunCappedOutput(neg, -1); err == nil {
+		t.Errorf("negative output cap accepted")
+	}
+	if neg.Process != nil {
+		t.Errorf("command started despite invalid output cap")
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- RunCappedOutput(exec.Command("sh", "-c", "while :; do echo flood; echo flood >&2; done"), 1024)
+	}()
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "exceeded 1024 bytes") {
+			t.Errorf("error mismatch: have %v, want output cap exceeded", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("flooding command not killed")
+	}
+}
+
+// Tests that command details are only described in verbose mode.
+func TestVerboseCommandDetails(t *testing.T) {
+	cmd := exec.Command("true")
+	cmd.Dir = "/src/go-ethereum"
+	cmd.Env = append(os.Environ(), "GOARCH=arm64")
+
+	if lines := commandDetails(cmd); len(lines) != 0 {
+		t.Errorf("terse mode printed details: %v", lines)
+	}
+	*VerboseFlag = true
+	defer func() { *VerboseFlag = false }()
+
+	want := []string{"dir: /src/go-ethereum", "env: GOARCH=arm64"}
+	if have := commandDetails(cmd); !reflect.DeepEqual(have, want) {
+		t.Errorf("verbose details mismatch: have %v, want %v", have, want)
+	}
+}
+
+// Tests that log grouping markers are only emitted on CI services supporting
+// them.
+func TestGroupMarkers(t *testing.T) {
+	vars := []string{"CI", "GITHUB_ACTIONS", "GITLAB_CI", "TRAVIS", "APPVEYOR"}
+	for _, key := range vars {
+		defer os.Setenv(key, os.Getenv(key))
+		os.Unsetenv(key)
+	}
+	if start, end := groupMarkers("Build geth"); start != "" || end != "" {
+		t.Errorf("local markers mismatch: have %q/%q, want none", start, end)
+	}
+	if IsCI() {
+		t.Errorf("local build detected as CI")
+	}
+	os.Setenv("GITHUB_ACTIONS", "true")
+	if start, end := groupMarkers("Build geth"); start != "::group::Build geth" || end != "::endgroup::" {
+		t.Errorf("github markers mismatch: have %q/%q", start, end)
+	}
+	os.Unsetenv("GITHUB_ACTIONS")
+	os.Setenv("GITLAB_CI", "true")
+	start, end := groupMarkers("Build geth")
+	if !strings.Contains(start, "section_start:") || !strings.Contains(start, ":build_geth") || !strings.HasSuffix(start, "Build geth") {
+		t.Errorf("gitlab start marker mismatch: have %q", start)
+	}
+	if !strings.Contains(end, "section_end:") || !strings.Contains(end, ":build_geth") {
+		t.Errorf("gitlab end marker mismatch: have %q", end)
+	}
+	os.Unsetenv("GITLAB_CI")
+	os.Setenv("CI", "True")
+	os.Setenv("APPVEYOR", "True")
+	if start, end := groupMarkers("Build geth"); start != "" || end != "" {
+		t.Errorf("appveyor markers mismatch: have %q/%q, want none", start, end)
+	}
+	if provider := CIProvider(); provider != "appveyor" {
+		t.Errorf("provider mismatch: have %q, want appveyor", provider)
+	}
+}
+
+// Tests that the section of a grouped command is closed even if it fails.
+func TestRunGroupedFailure(t *testing.T) {
+	skipNoShell(t)
+
+	defer os.Setenv("GITHUB_ACTIONS", os.Getenv("GITHUB_ACTIONS"))
+	os.Setenv("GITHUB_ACTIONS", "true")
+
+	var out bytes.Buffer
+	if err := runGrouped(&out, "Test geth", exec.Command("sh", "-c", "echo FAIL; exit 1")); err == nil {
+		t.Fatalf("failing command succeeded")
+	}
+	if have, want := out.String(), "::group::Test geth\n>>> sh -c echo FAIL; exit 1\nFAIL\n::endgroup::\n"; have != want {
+		t.Errorf("grouped output mismatch:\nhave %q\nwant %q", have, want)
+	}
+}
+
+// mustReadFile returns the contents of a file, failing the test on error.
+func mustReadFile(t *testing.T, path string) string {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+	return string(content)
+}
+
+// Tests that pipeline stages are connected end to end and that failures of a
+// middle stage are attributed to it.
+func TestRunPipeline(t *testing.T) {
+	skipNoShell(t)
+
+	dir, err := ioutil.TempDir("", "build-pipe-")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, "out")
+	err = RunPipeline(
+		exec.Command("sh", "-c", "printf 'geth\\nswarm\\nbootnode\\nevm\\n'"),
+		exec.Command("grep", "e"),
+		exec.Command("sh", "-c", "cat > "+out),
+	)
+	if err != nil {
+		t.Fatalf("failed to run pipeline: %v", err)
+	}
+	if have, want := mustReadFile(t, out), "geth\nbootnode\nevm\n"; have != want {
+		t.Errorf("pipeline output mismatch: have %q, want %q", have, want)
+	}
+	err = RunPipeline(
+		exec.Command("echo", "geth"),
+		exec.Command("sh", "-c", "cat >/dev/null; exit 3"),
+		exec.Command("cat"),
+	)
+	if err == nil {
+		t.Fatalf("failing pipeline succeeded")
+	}
+	if !strings.Contains(err.Error(), "stage 2") || !strings.Contains(err.Error(), "exit status 3") {
+		t.Errorf("error doesn't identify failing stage: %v", err)
+	}
+}
+
+// Tests that upstream stages killed by a broken pipe don't fail the pipeline,
+// like in a shell.
+func TestRunPipelineBrokenPipe(t *testing.T) {
+	skipNoShell(t)
+
+	err := RunPipeline(
+		exec.Command("yes"),
+		exec.Command("sh", "-c", "head -1 >/dev/null"),
+	)
+	if err != nil {
+		t.Fatalf("broken pipe reported as failure: %v", err)
+	}
+}
+
+// Tests that a stage failing to start is blamed instead of the stages killed
+// during cleanup.
+func TestRunPipelineStartFailure(t *testing.T) {
+	skipNoShell(t)
+
+	start := time.Now()
+	err := RunPipeline(
+		exec.Command("sleep", "5"),
+		exec.Command(filepath.Join("nonexistent", "abigen")),
+	)
+	if err == nil {
+		t.Fatalf("unstartable pipeline succeeded")
+	}
+	if !strings.Contains(err.Error(), "stage 2") || !strings.Contains(err.Error(), "failed to start") {
+		t.Errorf("error doesn't identify unstartable stage: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("started stages not killed: pipeline took %v", elapsed)
+	}
+}
+
+// Tests that commands are only retried on the designated exit codes.
+func TestRunRetryCodes(t *testing.T) {
+	skipNoShell(t)
+
+	dir, err := ioutil.TempDir("", "build-retry-")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	counter := filepath.Join(dir, "runs")
+
+	// Usage errors must not be retried
+	cmd := exec.Command("sh", "-c", "echo run >> "+counter+"; exit 2")
+	if err := RunRetryCodes(cmd, 5, time.Millisecond, []int{75}); err == nil {
+		t.Fatalf("failing command succeeded")
+	}
+	if runs := mustReadFile(t, counter); runs != "run\n" {
+		t.Errorf("non-retryable failure retried: %q", runs)
+	}
+	os.Remove(counter)
+
+	// Temporary failures are retried until the command succeeds
+	cmd = exec.Command("sh", "-c", "echo run >> "+counter+"; [ $(wc -l < "+counter+") -ge 3 ] || exit 75")
+	if err := RunRetryCodes(cmd, 5, time.Millisecond, []int{75}); err != nil {
+		t.Fatalf("retryable command failed: %v", err)
+	}
+	if runs := mustReadFile(t, counter); runs != "run\nrun\nrun\n" {
+		t.Errorf("attempt count mismatch: have %q, want 3 runs", runs)
+	}
+	os.Remove(counter)
+
+	// Retries are bounded by the attempt count
+	cmd = exec.Command("sh", "-c", "echo run >> "+counter+"; exit 75")
+	if err := RunRetryCodes(cmd, 2, time.Millisecond, []int{75}); err == nil {
+		t.Fatalf("persistently failing command succeeded")
+	}
+	if runs := mustReadFile(t, counter); runs != "run\nrun\n" {
+		t.Errorf("attempt count mismatch: have %q, want 2 runs", runs)
+	}
+}
+
+// Tests that tools in the extra PATH directories take precedence.
+func TestMustRunWithPath(t *testing.T) {
+	skipNoShell(t)
+
+	dir, err := ioutil.TempDir("", "build-path-")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, "out")
+	writeStub(t, dir, "true", `echo "stub $PATH" > `+out)
+
+	MustRunWithPath([]string{dir}, exec.Command("true"))
+	have := mustReadFile(t, out)
+	if want := "stub " + dir + string(os.PathListSeparator); !strings.HasPrefix(have, want) {
+		t.Errorf("stub invocation mismatch: have %q, want prefix %q", have, want)
+	}
+}
+
+// Tests that the output streams of a command are logged into separate files.
+func TestMustRunSplitLogs(t *testing.T) {
+	skipNoShell(t)
+
+	dir, err := ioutil.TempDir("", "build-logs-")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		stdout = filepath.Join(dir, "logs", "stdout.log")
+		stderr = filepath.Join(dir, "logs", "errors", "stderr.log")
+	)
+	MustRunSplitLogs(stdout, stderr, exec.Command("sh", "-c", "echo compiled; echo 'warning: unused' >&2; echo linked"))
+
+	if have, want := mustReadFile(t, stdout), "compiled\nlinked\n"; have != want {
+		t.Errorf("stdout log mismatch: have %q, want %q", have, want)
+	}
+	if have, want := mustReadFile(t, stderr), "warning: unused\n"; have != want {
+		t.Errorf("stderr log mismatch: have %q, want %q", have, want)
+	}
+}
+
+// Tests that the contents of an input file are fed into the command's stdin.
+func TestMustRunWithInputFile(t *testing.T) {
+	skipNoShell(t)
+
+	dir, err := ioutil.TempDir("", "build-input-")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		input  = filepath.Join(dir, "genesis.json")
+		output = filepath.Join(dir, "count")
+		blob   = bytes.Repeat([]byte("0123456789abcdef"), 4096)
+	)
+	if err := ioutil.WriteFile(input, blob, 0644); err != nil {
+		t.Fatalf("failed to write input: %v", err)
+	}
+	MustRunWithInputFile(input, exec.Command("sh", "-c", `wc -c > "$0"`, output))
+
+	if have, want := strings.TrimSpace(mustReadFile(t, output)), strconv.Itoa(len(blob)); have != want {
+		t.Errorf("byte count mismatch: have %s, want %s", have, want)
+	}
+}
+
+// Tests that output lines are recorded in order with increasing timestamps.
+func TestRunTimestamped(t *testing.T) {
+	skipNoShell(t)
+
+	lines := RunTimestamped(exec.Command("sh", "-c", "echo compile; sleep 0.1; echo link >&2; sleep 0.1; printf done"))
+	if len(lines) != 3 {
+		t.Fatalf("line count mismatch: have %d, want 3: %v", len(lines), lines)
+	}
+	for i, want := range []string{"compile", "link", "done"} {
+		if lines[i].Text != want {
+			t.Errorf("line %d mismatch: have %q, want %q", i, lines[i].Text, want)
+		}
+		if i > 0 && lines[i].Elapsed <= lines[i-1].Elapsed {
+			t.Errorf("line %d timestamp not increasing: %v after %v", i, lines[i].Elapsed, lines[i-1].Elapsed)
+		}
+	}
+	if lines[2].Elapsed < 200*time.Millisecond {
+		t.Errorf("final line timestamp too early: %v", lines[2].Elapsed)
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// minisignLines returns the base64 payload lines of a minisign key or signature,
+// skipping the untrusted comment, along with the trusted comment (if any).
+func minisignLines(data string) ([]string, string) {
+	var (
+		payloads []string
+		trusted  string
+	)
+	for _, line := range strings.Split(strings.Replace(data, "\r\n", "\n", -1), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "", strings.HasPrefix(line, "untrusted comment:"):
+		case strings.HasPrefix(line, "trusted comment: "):
+			trusted = strings.TrimPrefix(line, "trusted comment: ")
+		default:
+			payloads = append(payloads, line)
+		}
+	}
+	return payloads, trusted
+}
+
+// VerifySignature checks the minisign signature in sigPath against the contents
+// of filePath, using the given minisign public key (either the base64 key line
+// or the full key file contents). If the signature carries a trusted comment,
+// its global signature is verified as well.
+//
+// Only legacy "Ed" signatures, as created by `minisign -S -l`, are accepted.
+// Prehashed "ED" signatures, the default output of minisign 0.10 and later, are
+// rejected since verifying them needs BLAKE2b, which is not vendored.
+func VerifySignature(filePath, sigPath, pubKey string) error {
+	// Decode the public key: "Ed" || key id (8 bytes) || ed25519 key (32 bytes)
+	lines, _ := minisignLines(pubKey)
+	if len(lines) != 1 {
+		return fmt.Errorf("invalid public key: want a single key line")
+	}
+	key, err := base64.StdEncoding.DecodeString(lines[0])
+	if err != nil || len(key) != 2+8+ed25519.PublicKeySize || string(key[:2]) != "Ed" {
+		return fmt.Errorf("invalid public key: not a minisign ed25519 key")
+	}
+	keyID, pub := key[2:10], ed25519.PublicKey(key[10:])
+
+	// Decode the signature: "Ed" || key id (8 bytes) || ed25519 signature (64 bytes)
+	sigFile, err := ioutil.ReadFile(sigPath)
+	if err != nil {
+		return err
+	}
+	lines, trusted := minisignLines(string(sigFile))
+	if len(lines) == 0 || len(lines) > 2 {
+		return fmt.Errorf("%s: invalid signature file", sigPath)
+	}
+	sig, err := base64.StdEncoding.DecodeString(lines[0])
+	if err != nil || len(sig) != 2+8+ed25519.SignatureSize {
+		return fmt.Errorf("%s: invalid signature", sigPath)
+	}
+	switch string(sig[:2]) {
+	case "Ed":
+	case "ED":
+		return fmt.Errorf("%s: prehashed signatures are not supported", sigPath)
+	default:
+		return fmt.Errorf("%s: unknown signature algorithm %q", sigPath, sig[:2])
+	}
+	if !bytes.Equal(sig[2:10], keyID) {
+		return fmt.Errorf("%s: signed by key %X, not %X", sigPath, sig[2:10], keyID)
+	}
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(pub, content, sig[10:]) {
+		return fmt.Errorf("%s: signature verification failed", filePath)
+	}
+	// Verify the global signature over the signature and trusted comment
+	if len(lines) == 2 {
+		global, err := base64.StdEncoding.DecodeString(lines[1])
+		if err != nil || len(global) != ed25519.SignatureSize {
+			return fmt.Errorf("%s: invalid global signature", sigPath)
+		}
+		if !ed25519.Verify(pub, append(append([]byte(nil), sig[10:]...), trusted...), global) {
+			return fmt.Errorf("%s: trusted comment verification failed", sigPath)
+		}
+	}
+	return nil
+}
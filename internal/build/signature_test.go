@@ -0,0 +1,99 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// minisignTestKey generates a key pair, returning the private key and the
+// public key in minisign's file format.
+func minisignTestKey(t *testing.T) (ed25519.PrivateKey, []byte, string) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	keyID := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	blob := append(append([]byte("Ed"), keyID...), pub...)
+	return priv, keyID, "untrusted comment: minisign public key 0807060504030201\n" + base64.StdEncoding.EncodeToString(blob) + "\n"
+}
+
+// minisignTestSign creates a minisign signature file of content.
+func minisignTestSign(priv ed25519.PrivateKey, keyID, content []byte, comment string) string {
+	sig := ed25519.Sign(priv, content)
+	global := ed25519.Sign(priv, append(append([]byte(nil), sig...), comment...))
+	blob := append(append([]byte("Ed"), keyID...), sig...)
+	return "untrusted comment: signature from minisign secret key\n" +
+		base64.StdEncoding.EncodeToString(blob) + "\n" +
+		"trusted comment: " + comment + "\n" +
+		base64.StdEncoding.EncodeToString(global) + "\n"
+}
+
+// Tests that correctly signed files are accepted while tampered files and
+// signatures are rejected.
+func TestVerifySignature(t *testing.T) {
+	dir, err := ioutil.TempDir("", "build-sig-")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	priv, keyID, pubKey := minisignTestKey(t)
+	content := []byte("go1.8.1.linux-amd64.tar.gz contents")
+
+	var (
+		file = filepath.Join(dir, "go.tar.gz")
+		sig  = filepath.Join(dir, "go.tar.gz.minisig")
+	)
+	write := func(path string, data []byte) {
+		if err := ioutil.WriteFile(path, data, 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+	write(file, content)
+	write(sig, []byte(minisignTestSign(priv, keyID, content, "timestamp:1493638200")))
+	if err := VerifySignature(file, sig, pubKey); err != nil {
+		t.Fatalf("valid signature rejected: %v", err)
+	}
+	// Tamper with the file and ensure it's rejected
+	write(file, append(content, '!'))
+	if err := VerifySignature(file, sig, pubKey); err == nil {
+		t.Errorf("tampered file accepted")
+	}
+	write(file, content)
+
+	// Tamper with the trusted comment and ensure it's rejected
+	signature, _ := ioutil.ReadFile(sig)
+	write(sig, []byte(strings.Replace(string(signature), "timestamp:1493638200", "timestamp:1493638201", 1)))
+	if err := VerifySignature(file, sig, pubKey); err == nil {
+		t.Errorf("tampered trusted comment accepted")
+	}
+	// Verify with a different key and ensure it's rejected
+	_, _, otherKey := minisignTestKey(t)
+	write(sig, signature)
+	if err := VerifySignature(file, sig, otherKey); err == nil {
+		t.Errorf("signature accepted by unrelated key")
+	}
+}
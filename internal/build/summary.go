@@ -0,0 +1,69 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// summaryGoEnv are the go env variables included in the EnvSummary.
+var summaryGoEnv = []string{"GOOS", "GOARCH", "GOROOT", "GOPATH", "GOFLAGS", "CGO_ENABLED"}
+
+// summaryEnv are the process environment variables included in the EnvSummary.
+var summaryEnv = []string{"CC", "CXX", "GOARM", "CGO_CFLAGS", "CGO_LDFLAGS"}
+
+// EnvSummary returns a multi-line description of the build environment (host
+// platform, Go toolchain, relevant environment variables and git state), suitable
+// for logging at the start of a build to diagnose environment specific issues.
+func EnvSummary() string {
+	var buf bytes.Buffer
+	line := func(key, value string) {
+		fmt.Fprintf(&buf, "%-12s %s\n", key+":", value)
+	}
+	line("host", runtime.GOOS+"/"+runtime.GOARCH)
+
+	if out, err := exec.Command(goBinary, "version").Output(); err != nil {
+		line("go", "unavailable ("+err.Error()+")")
+	} else {
+		line("go", strings.TrimSpace(string(out)))
+	}
+	if out, err := exec.Command(goBinary, append([]string{"env"}, summaryGoEnv...)...).Output(); err == nil {
+		values := strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+		for i, key := range summaryGoEnv {
+			if i < len(values) {
+				line(key, values[i])
+			}
+		}
+	}
+	for _, key := range summaryEnv {
+		if value := os.Getenv(key); value != "" {
+			line(key, value)
+		}
+	}
+	if commit, err := tryGit("rev-parse", "HEAD"); err != nil {
+		line("commit", "unknown")
+	} else {
+		line("commit", commit)
+		line("dirty", fmt.Sprint(GitDirty()))
+	}
+	return buf.String()
+}
@@ -0,0 +1,56 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// Tests that the environment summary reports the toolchain, host and git state.
+func TestEnvSummary(t *testing.T) {
+	dir, cleanup := stubGo(t, `
+case "$1" in
+	version) echo "go version go1.8.1 linux/amd64" ;;
+	env)     printf 'linux\namd64\n/usr/local/go\n/home/go\n\n1\n' ;;
+esac
+`)
+	defer cleanup()
+
+	defer func(git string) { gitBinary = git }(gitBinary)
+	gitBinary = writeStub(t, dir, "git", `
+case "$1" in
+	rev-parse) echo 0123456789abcdef0123456789abcdef01234567 ;;
+	status)    echo " M README.md" ;;
+esac
+`)
+	summary := EnvSummary()
+	for _, want := range []string{
+		"host:        " + runtime.GOOS + "/" + runtime.GOARCH,
+		"go:          go version go1.8.1 linux/amd64",
+		"GOOS:        linux",
+		"GOROOT:      /usr/local/go",
+		"CGO_ENABLED: 1",
+		"commit:      0123456789abcdef0123456789abcdef01234567",
+		"dirty:       true",
+	} {
+		if !strings.Contains(summary, want+"\n") {
+			t.Errorf("summary missing %q:\n%s", want, summary)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// TargetsEnvVar is the environment variable overriding the release target matrix
+// with a comma separated list of os/arch pairs (e.g. "linux/amd64,darwin/amd64").
+const TargetsEnvVar = "BUILD_TARGETS"
+
+// Target is a single cross compilation target.
+type Target struct {
+	GOOS   string
+	GOARCH string
+}
+
+func (t Target) String() string {
+	return t.GOOS + "/" + t.GOARCH
+}
+
+// knownOS and knownArch are the operating systems and architectures accepted by
+// ParseTarget if the installed toolchain can't be queried.
+var (
+	knownOS = []string{
+		"android", "darwin", "dragonfly", "freebsd", "linux", "nacl",
+		"netbsd", "openbsd", "plan9", "solaris", "windows",
+	}
+	knownArch = []string{
+		"386", "amd64", "amd64p32", "arm", "arm64", "mips", "mipsle",
+		"mips64", "mips64le", "ppc64", "ppc64le", "s390x",
+	}
+)
+
+// SupportedTargets returns all platforms the installed Go toolchain can build
+// for, as reported by go tool dist list.
+func SupportedTargets() ([]Target, error) {
+	out, err := exec.Command(goBinary, "tool", "dist", "list").Output()
+	if err != nil {
+		return nil, fmt.Errorf("go tool dist list failed: %v", err)
+	}
+	var targets []Target
+	for _, line := range strings.Split(string(out), "\n") {
+		parts := strings.Split(strings.TrimSpace(line), "/")
+		if len(parts) == 2 {
+			targets = append(targets, Target{GOOS: parts[0], GOARCH: parts[1]})
+		}
+	}
+	return targets, nil
+}
+
+// ParseTarget parses an os/arch pair into a Target, rejecting platforms which
+// the installed toolchain doesn't support. If the toolchain can't be queried,
+// the pair is validated against a static list of known values.
+func ParseTarget(s string) (Target, error) {
+	parts := strings.Split(strings.TrimSpace(s), "/")
+	if len(parts) != 2 {
+		return Target{}, fmt.Errorf("invalid target %q, want os/arch", s)
+	}
+	t := Target{GOOS: parts[0], GOARCH: parts[1]}
+
+	if supported, err := SupportedTargets(); err == nil {
+		for _, target := range supported {
+			if target == t {
+				return t, nil
+			}
+		}
+		return Target{}, fmt.Errorf("invalid target %q: not supported by the installed toolchain", s)
+	}
+	if !contains(knownOS, t.GOOS) {
+		return Target{}, fmt.Errorf("invalid target %q: unknown GOOS %q", s, t.GOOS)
+	}
+	if !contains(knownArch, t.GOARCH) {
+		return Target{}, fmt.Errorf("invalid target %q: unknown GOARCH %q", s, t.GOARCH)
+	}
+	return t, nil
+}
+
+// DefaultTargets returns the platforms release archives are built for.
+func DefaultTargets() []Target {
+	return []Target{
+		{"linux", "amd64"},
+		{"linux", "386"},
+		{"linux", "arm"},
+		{"linux", "arm64"},
+		{"linux", "mips"},
+		{"linux", "mipsle"},
+		{"linux", "mips64"},
+		{"linux", "mips64le"},
+		{"darwin", "amd64"},
+		{"windows", "amd64"},
+		{"windows", "386"},
+	}
+}
+
+// TargetsFromEnv returns the release targets listed in the BUILD_TARGETS
+// environment variable, falling back to DefaultTargets if it is not set. Invalid
+// entries terminate the process.
+func TargetsFromEnv() []Target {
+	spec := strings.TrimSpace(os.Getenv(TargetsEnvVar))
+	if spec == "" {
+		return DefaultTargets()
+	}
+	var targets []Target
+	for _, entry := range strings.Split(spec, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		t, err := ParseTarget(entry)
+		if err != nil {
+			log.Fatalf("%s: %v", TargetsEnvVar, err)
+		}
+		targets = append(targets, t)
+	}
+	return targets
+}
+
+// OutputName returns the conventional output path of a binary built for the
+// given platform: dist/<base>-<version>-<goos>-<goarch>, with an .exe suffix on
+// Windows.
+func OutputName(base, version, goos, goarch string) string {
+	name := strings.Join([]string{base, version, goos, goarch}, "-")
+	if goos == "windows" {
+		name += ".exe"
+	}
+	return filepath.Join("dist", name)
+}
+
+// debianArch maps Go architectures to their Debian names.
+var debianArch = map[string]string{
+	"386":      "i386",
+	"amd64":    "amd64",
+	"arm":      "armhf",
+	"arm64":    "arm64",
+	"mips":     "mips",
+	"mipsle":   "mipsel",
+	"mips64le": "mips64el",
+	"ppc64le":  "ppc64el",
+	"s390x":    "s390x",
+}
+
+// RepoLayout returns the conventional path of a release artifact relative to
+// the root of a distribution repository of the given type:
+//
+//	apt:     pool/main/<b>/<base>/<base>_<version>_<debarch>.deb (Linux only)
+//	brew:    bottles/<base>-<version>.<goos>_<goarch>.bottle.tar.gz (macOS and Linux)
+//	generic: <base>/<version>/<base>-<goos>-<goarch>-<version>.tar.gz (or .zip on Windows)
+func RepoLayout(repoType, base, version, goos, goarch string) (string, error) {
+	if base == "" {
+		return "", fmt.Errorf("empty artifact base name")
+	}
+	switch repoType {
+	case "apt":
+		arch, ok := debianArch[goarch]
+		if goos != "linux" || !ok {
+			return "", fmt.Errorf("apt repositories don't support %s/%s", goos, goarch)
+		}
+		return path.Join("pool", "main", base[:1], base, fmt.Sprintf("%s_%s_%s.deb", base, version, arch)), nil
+	case "brew":
+		if goos != "darwin" && goos != "linux" {
+			return "", fmt.Errorf("brew repositories don't support %s/%s", goos, goarch)
+		}
+		return path.Join("bottles", fmt.Sprintf("%s-%s.%s_%s.bottle.tar.gz", base, version, goos, goarch)), nil
+	case "generic":
+		ext := ".tar.gz"
+		if goos == "windows" {
+			ext = ".zip"
+		}
+		return path.Join(base, version, strings.Join([]string{base, goos, goarch, version}, "-")+ext), nil
+	default:
+		return "", fmt.Errorf("unknown repository type %q, want apt, brew or generic", repoType)
+	}
+}
+
+// nativeCompat lists the foreign architectures whose binaries hosts can run
+// natively, keyed by host architecture.
+var nativeCompat = map[string][]string{
+	"amd64": {"386"},
+	"arm64": {"arm"},
+}
+
+// IsEmulated reports whether binaries built for goarch would run emulated on the
+// host (e.g. amd64 tests through Rosetta on an arm64 Mac, or through qemu user
+// emulation on Linux), rather than natively. 32 bit binaries on 64 bit hosts of
+// the same family are considered native, except on macOS which can't run them.
+func IsEmulated(goarch string) bool {
+	return isEmulated(runtime.GOOS, runtime.GOARCH, goarch)
+}
+
+func isEmulated(hostOS, hostArch, goarch string) bool {
+	if goarch == hostArch {
+		return false
+	}
+	if hostOS != "darwin" && contains(nativeCompat[hostArch], goarch) {
+		return false
+	}
+	return true
+}
+
+// GOARMFor returns the GOARM value to build the given architecture with: the
+// GOARM environment variable if set, ARMv7 otherwise. Non-arm architectures
+// yield an empty string.
+func GOARMFor(goarch string) string {
+	if goarch != "arm" {
+		return ""
+	}
+	if goarm := os.Getenv("GOARM"); goarm != "" {
+		return goarm
+	}
+	return "7"
+}
+
+// crossEnv returns the environment overrides needed to build for a target.
+func crossEnv(t Target) map[string]string {
+	env := map[string]string{
+		"GOOS":   t.GOOS,
+		"GOARCH": t.GOARCH,
+	}
+	if goarm := GOARMFor(t.GOARCH); goarm != "" {
+		env["GOARM"] = goarm
+	}
+	return env
+}
+
+// NonReproducible disables the ReproducibleBuildFlags in CrossBuild, e.g. for
+// development builds which want VCS stamping.
+var NonReproducible bool
+
+// ReproducibleBuildFlags returns the go build flags making the output independent
+// of the build machine, as far as the installed go tool supports them: the build
+// ID is left empty, file system paths are trimmed (Go 1.13+) and VCS stamping is
+// turned off (Go 1.18+).
+func ReproducibleBuildFlags() []string {
+	var flags []string
+	if major, minor, err := goVersion(); err != nil {
+		log.Printf("Warning: can't determine go version, skipping newer reproducibility flags: %v", err)
+	} else {
+		if major > 1 || minor >= 13 {
+			flags = append(flags, "-trimpath")
+		}
+		if major > 1 || minor >= 18 {
+			flags = append(flags, "-buildvcs=false")
+		}
+	}
+	return append(flags, "-ldflags=-buildid=")
+}
+
+// CrossBuild compiles the given packages for a target platform, writing the
+// result to output. Unless NonReproducible is set, ReproducibleBuildFlags are
+// passed to go build.
+func CrossBuild(t Target, output string, pkgs ...string) error {
+	args := []string{"build"}
+	if !NonReproducible {
+		args = append(args, ReproducibleBuildFlags()...)
+	}
+	args = append(append(args, "-o", output), pkgs...)
+	cmd := exec.Command(goBinary, args...)
+
+	env := crossEnv(t)
+	for _, kv := range os.Environ() {
+		if _, override := env[strings.SplitN(kv, "=", 2)[0]]; !override {
+			cmd.Env = append(cmd.Env, kv)
+		}
+	}
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		cmd.Env = append(cmd.Env, key+"="+env[key])
+	}
+	if err := runCommand(cmd); err != nil {
+		return fmt.Errorf("build for %v failed: %v", t, err)
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,282 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// Tests that the default release matrix contains the expected platforms.
+func TestDefaultTargets(t *testing.T) {
+	targets := DefaultTargets()
+	for _, want := range []Target{{"linux", "amd64"}, {"linux", "arm64"}, {"darwin", "amd64"}, {"windows", "386"}} {
+		found := false
+		for _, target := range targets {
+			if target == want {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("default targets missing %v", want)
+		}
+	}
+}
+
+// Tests that the environment override replaces the default release matrix.
+func TestTargetsFromEnv(t *testing.T) {
+	defer os.Setenv(TargetsEnvVar, os.Getenv(TargetsEnvVar))
+
+	os.Setenv(TargetsEnvVar, "")
+	if have := TargetsFromEnv(); !reflect.DeepEqual(have, DefaultTargets()) {
+		t.Errorf("unset override mismatch: have %v, want %v", have, DefaultTargets())
+	}
+	os.Setenv(TargetsEnvVar, "linux/arm, freebsd/amd64")
+	want := []Target{{"linux", "arm"}, {"freebsd", "amd64"}}
+	if have := TargetsFromEnv(); !reflect.DeepEqual(have, want) {
+		t.Errorf("override mismatch: have %v, want %v", have, want)
+	}
+}
+
+// Tests that malformed and unknown targets are rejected.
+func TestParseTarget(t *testing.T) {
+	// Validate against the static list, regardless of the installed toolchain
+	defer func(gobin string) { goBinary = gobin }(goBinary)
+	goBinary = "/nonexistent/go"
+
+	for _, spec := range []string{"linux", "linux/amd64/v2", "beos/amd64", "linux/z80"} {
+		if target, err := ParseTarget(spec); err == nil {
+			t.Errorf("invalid target %q accepted as %v", spec, target)
+		}
+	}
+	if target, err := ParseTarget("linux/arm64"); err != nil || target != (Target{"linux", "arm64"}) {
+		t.Errorf("valid target rejected: have %v, %v", target, err)
+	}
+}
+
+// Tests that the platforms reported by the toolchain are parsed and used for
+// target validation.
+func TestSupportedTargets(t *testing.T) {
+	_, cleanup := stubGo(t, `printf 'darwin/arm64\nlinux/amd64\nlinux/riscv64\n'`)
+	defer cleanup()
+
+	targets, err := SupportedTargets()
+	if err != nil {
+		t.Fatalf("failed to list supported targets: %v", err)
+	}
+	want := []Target{{"darwin", "arm64"}, {"linux", "amd64"}, {"linux", "riscv64"}}
+	if !reflect.DeepEqual(targets, want) {
+		t.Errorf("supported targets mismatch: have %v, want %v", targets, want)
+	}
+	if _, err := ParseTarget("linux/riscv64"); err != nil {
+		t.Errorf("toolchain supported target rejected: %v", err)
+	}
+	if _, err := ParseTarget("linux/386"); err == nil {
+		t.Errorf("toolchain unsupported target accepted")
+	}
+}
+
+// Tests that binary output names follow the distribution convention.
+func TestOutputName(t *testing.T) {
+	tests := []struct {
+		goos, goarch string
+		want         string
+	}{
+		{"windows", "amd64", "dist/geth-1.6.1-windows-amd64.exe"},
+		{"linux", "arm64", "dist/geth-1.6.1-linux-arm64"},
+	}
+	for _, tt := range tests {
+		if have := OutputName("geth", "1.6.1", tt.goos, tt.goarch); have != filepath.FromSlash(tt.want) {
+			t.Errorf("%s/%s: name mismatch: have %s, want %s", tt.goos, tt.goarch, have, tt.want)
+		}
+	}
+}
+
+// Tests that GOARM is only set for 32 bit ARM targets and can be overridden.
+func TestGOARMFor(t *testing.T) {
+	defer os.Setenv("GOARM", os.Getenv("GOARM"))
+
+	os.Setenv("GOARM", "")
+	tests := []struct {
+		goarch, want string
+	}{
+		{"arm", "7"},
+		{"arm64", ""},
+		{"amd64", ""},
+	}
+	for i, tt := range tests {
+		if have := GOARMFor(tt.goarch); have != tt.want {
+			t.Errorf("test %d: GOARM mismatch for %s: have %q, want %q", i, tt.goarch, have, tt.want)
+		}
+	}
+	os.Setenv("GOARM", "6")
+	if have := GOARMFor("arm"); have != "6" {
+		t.Errorf("overridden GOARM mismatch: have %q, want %q", have, "6")
+	}
+	if have := GOARMFor("arm64"); have != "" {
+		t.Errorf("overridden GOARM leaked to arm64: have %q", have)
+	}
+	want := map[string]string{"GOOS": "linux", "GOARCH": "arm", "GOARM": "6"}
+	if have := crossEnv(Target{"linux", "arm"}); !reflect.DeepEqual(have, want) {
+		t.Errorf("cross env mismatch: have %v, want %v", have, want)
+	}
+}
+
+// Tests that cross builds run go build with the target's environment.
+func TestCrossBuild(t *testing.T) {
+	dir, cleanup := stubGo(t, `
+[ "$1" = "version" ] && { echo "go version go1.18.1 linux/amd64"; exit 0; }
+echo "$@ $GOOS $GOARCH $GOARM" > "$(dirname "$0")/calls"
+`)
+	defer cleanup()
+	defer os.Setenv("GOARM", os.Getenv("GOARM"))
+	os.Setenv("GOARM", "")
+
+	if err := CrossBuild(Target{"linux", "arm"}, "geth", "./cmd/geth"); err != nil {
+		t.Fatalf("failed to cross build: %v", err)
+	}
+	calls, err := ioutil.ReadFile(filepath.Join(dir, "calls"))
+	if err != nil {
+		t.Fatalf("failed to read recorded calls: %v", err)
+	}
+	if want := "build -trimpath -buildvcs=false -ldflags=-buildid= -o geth ./cmd/geth linux arm 7\n"; string(calls) != want {
+		t.Errorf("go build invocation mismatch:\nhave %q\nwant %q", calls, want)
+	}
+}
+
+// Tests that reproducibility flags are only used if the go tool supports them.
+func TestReproducibleBuildFlags(t *testing.T) {
+	tests := []struct {
+		version string
+		want    []string
+	}{
+		{"go1.7.5", []string{"-ldflags=-buildid="}},
+		{"go1.8.1", []string{"-ldflags=-buildid="}},
+		{"go1.13beta1", []string{"-trimpath", "-ldflags=-buildid="}},
+		{"go1.18", []string{"-trimpath", "-buildvcs=false", "-ldflags=-buildid="}},
+		{"go2.0.0", []string{"-trimpath", "-buildvcs=false", "-ldflags=-buildid="}},
+		{"devel", []string{"-trimpath", "-buildvcs=false", "-ldflags=-buildid="}},
+	}
+	for i, tt := range tests {
+		_, cleanup := stubGo(t, `echo "go version `+tt.version+` linux/amd64"`)
+		have := ReproducibleBuildFlags()
+		cleanup()
+
+		if !reflect.DeepEqual(have, tt.want) {
+			t.Errorf("test %d: %s flags mismatch: have %v, want %v", i, tt.version, have, tt.want)
+		}
+	}
+}
+
+// Tests that cross builds are reproducible unless explicitly disabled, passing
+// only the flags the installed go tool supports.
+func TestCrossBuildReproducible(t *testing.T) {
+	for _, version := range []string{"go1.8.1", "go1.18.1"} {
+		dir, cleanup := stubGo(t, `
+[ "$1" = "version" ] && { echo "go version `+version+` linux/amd64"; exit 0; }
+echo "$@" > "$(dirname "$0")/calls"
+`)
+		for _, disabled := range []bool{false, true} {
+			NonReproducible = disabled
+			err := CrossBuild(Target{"linux", "amd64"}, "geth", "./cmd/geth")
+			NonReproducible = false
+			if err != nil {
+				t.Fatalf("%s: failed to cross build: %v", version, err)
+			}
+			calls, err := ioutil.ReadFile(filepath.Join(dir, "calls"))
+			if err != nil {
+				t.Fatalf("%s: failed to read recorded calls: %v", version, err)
+			}
+			want := "build -o geth ./cmd/geth\n"
+			if !disabled {
+				want = "build " + strings.Join(ReproducibleBuildFlags(), " ") + " -o geth ./cmd/geth\n"
+			}
+			if string(calls) != want {
+				t.Errorf("%s: non-reproducible %v: invocation mismatch: have %q, want %q", version, disabled, calls, want)
+			}
+			if version == "go1.8.1" && strings.Contains(string(calls), "-trimpath") {
+				t.Errorf("%s: unsupported -trimpath passed", version)
+			}
+		}
+		cleanup()
+	}
+}
+
+// Tests that artifacts are laid out according to the repository conventions.
+func TestRepoLayout(t *testing.T) {
+	tests := []struct {
+		repo, goos, goarch string
+		want               string
+		fail               bool
+	}{
+		{repo: "apt", goos: "linux", goarch: "arm", want: "pool/main/g/geth/geth_1.6.1_armhf.deb"},
+		{repo: "apt", goos: "linux", goarch: "386", want: "pool/main/g/geth/geth_1.6.1_i386.deb"},
+		{repo: "brew", goos: "darwin", goarch: "amd64", want: "bottles/geth-1.6.1.darwin_amd64.bottle.tar.gz"},
+		{repo: "generic", goos: "linux", goarch: "amd64", want: "geth/1.6.1/geth-linux-amd64-1.6.1.tar.gz"},
+		{repo: "generic", goos: "windows", goarch: "386", want: "geth/1.6.1/geth-windows-386-1.6.1.zip"},
+		{repo: "apt", goos: "darwin", goarch: "amd64", fail: true},
+		{repo: "brew", goos: "windows", goarch: "amd64", fail: true},
+		{repo: "rpm", goos: "linux", goarch: "amd64", fail: true},
+	}
+	for i, tt := range tests {
+		have, err := RepoLayout(tt.repo, "geth", "1.6.1", tt.goos, tt.goarch)
+		switch {
+		case tt.fail && err == nil:
+			t.Errorf("test %d: unsupported %s layout for %s/%s accepted: %s", i, tt.repo, tt.goos, tt.goarch, have)
+		case !tt.fail && err != nil:
+			t.Errorf("test %d: %s layout for %s/%s rejected: %v", i, tt.repo, tt.goos, tt.goarch, err)
+		case !tt.fail && have != tt.want:
+			t.Errorf("test %d: path mismatch: have %q, want %q", i, have, tt.want)
+		}
+	}
+}
+
+// Tests that foreign architectures are detected as emulated, while native ones
+// and their 32 bit siblings aren't.
+func TestIsEmulated(t *testing.T) {
+	if IsEmulated(runtime.GOARCH) {
+		t.Errorf("native architecture %s reported as emulated", runtime.GOARCH)
+	}
+	foreign := "amd64"
+	if runtime.GOARCH == "amd64" {
+		foreign = "arm64"
+	}
+	if !IsEmulated(foreign) {
+		t.Errorf("foreign architecture %s on %s host not reported as emulated", foreign, runtime.GOARCH)
+	}
+	tests := []struct {
+		hostOS, hostArch, goarch string
+		want                     bool
+	}{
+		{"darwin", "arm64", "amd64", true},
+		{"darwin", "amd64", "amd64", false},
+		{"darwin", "amd64", "386", true},
+		{"linux", "amd64", "386", false},
+		{"linux", "arm64", "arm", false},
+		{"linux", "amd64", "arm64", true},
+	}
+	for i, tt := range tests {
+		if have := isEmulated(tt.hostOS, tt.hostArch, tt.goarch); have != tt.want {
+			t.Errorf("test %d: %s on %s/%s emulation mismatch: have %v, want %v", i, tt.goarch, tt.hostOS, tt.hostArch, have, tt.want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// testEvent is a single event of the go test -json output stream.
+type testEvent struct {
+	Action  string
+	Package string
+	Test    string
+}
+
+// ParseTestSummary tallies the outcomes of the tests (including subtests) in a
+// go test -json event stream. Failing tests are reported as package.TestName in
+// the order they finished. Package level results are not counted.
+func ParseTestSummary(jsonOutput io.Reader) (passed, failed, skipped int, failures []string, err error) {
+	dec := json.NewDecoder(jsonOutput)
+	for {
+		var event testEvent
+		if err := dec.Decode(&event); err == io.EOF {
+			break
+		} else if err != nil {
+			return passed, failed, skipped, failures, err
+		}
+		if event.Test == "" {
+			continue
+		}
+		switch event.Action {
+		case "pass":
+			passed++
+		case "fail":
+			failed++
+			failures = append(failures, event.Package+"."+event.Test)
+		case "skip":
+			skipped++
+		}
+	}
+	return passed, failed, skipped, failures, nil
+}
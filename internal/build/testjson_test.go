@@ -0,0 +1,57 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// recordedTestJSON is a trimmed go test -json stream of two packages.
+const recordedTestJSON = `{"Time":"2017-05-02T10:00:00Z","Action":"run","Package":"example.com/a","Test":"TestOK"}
+{"Time":"2017-05-02T10:00:00Z","Action":"output","Package":"example.com/a","Test":"TestOK","Output":"=== RUN   TestOK\n"}
+{"Time":"2017-05-02T10:00:00Z","Action":"pass","Package":"example.com/a","Test":"TestOK","Elapsed":0}
+{"Time":"2017-05-02T10:00:00Z","Action":"run","Package":"example.com/a","Test":"TestBroken"}
+{"Time":"2017-05-02T10:00:00Z","Action":"run","Package":"example.com/a","Test":"TestBroken/sub"}
+{"Time":"2017-05-02T10:00:00Z","Action":"fail","Package":"example.com/a","Test":"TestBroken/sub","Elapsed":0}
+{"Time":"2017-05-02T10:00:00Z","Action":"fail","Package":"example.com/a","Test":"TestBroken","Elapsed":0}
+{"Time":"2017-05-02T10:00:00Z","Action":"fail","Package":"example.com/a","Elapsed":0.01}
+{"Time":"2017-05-02T10:00:00Z","Action":"run","Package":"example.com/b","Test":"TestSlow"}
+{"Time":"2017-05-02T10:00:00Z","Action":"skip","Package":"example.com/b","Test":"TestSlow","Elapsed":0}
+{"Time":"2017-05-02T10:00:00Z","Action":"run","Package":"example.com/b","Test":"TestFast"}
+{"Time":"2017-05-02T10:00:00Z","Action":"pass","Package":"example.com/b","Test":"TestFast","Elapsed":0}
+{"Time":"2017-05-02T10:00:00Z","Action":"pass","Package":"example.com/b","Elapsed":0.01}
+`
+
+// Tests that test outcomes are tallied from a recorded go test -json stream.
+func TestParseTestSummary(t *testing.T) {
+	passed, failed, skipped, failures, err := ParseTestSummary(strings.NewReader(recordedTestJSON))
+	if err != nil {
+		t.Fatalf("failed to parse test output: %v", err)
+	}
+	if passed != 2 || failed != 2 || skipped != 1 {
+		t.Errorf("count mismatch: have %d/%d/%d passed/failed/skipped, want 2/2/1", passed, failed, skipped)
+	}
+	want := []string{"example.com/a.TestBroken/sub", "example.com/a.TestBroken"}
+	if !reflect.DeepEqual(failures, want) {
+		t.Errorf("failures mismatch: have %v, want %v", failures, want)
+	}
+	if _, _, _, _, err := ParseTestSummary(strings.NewReader("not json\n")); err == nil {
+		t.Errorf("malformed stream accepted")
+	}
+}
@@ -33,19 +33,45 @@ import (
 
 var DryRunFlag = flag.Bool("n", false, "dry run, don't execute commands")
 
+var VerboseFlag = flag.Bool("verbose", false, "print the environment and directory of executed commands")
+
+// RenderRoot, if set, confines the output files of the render helpers. Output
+// paths are then interpreted relative to it and may not escape it.
+var RenderRoot string
+
 // MustRun executes the given command and exits the host process for
-// any error.
+// any error, including the build being aborted.
 func MustRun(cmd *exec.Cmd) {
 	fmt.Println(">>>", strings.Join(cmd.Args, " "))
+	for _, line := range commandDetails(cmd) {
+		fmt.Println("   ", line)
+	}
 	if !*DryRunFlag {
 		cmd.Stderr = os.Stderr
 		cmd.Stdout = os.Stdout
-		if err := cmd.Run(); err != nil {
+		if err := runAbortable(cmd); err != nil {
 			log.Fatal(err)
 		}
 	}
 }
 
+// commandDetails returns the lines describing the working directory and the
+// environment overrides of a command, if VerboseFlag is set.
+func commandDetails(cmd *exec.Cmd) []string {
+	if !*VerboseFlag {
+		return nil
+	}
+	dir := cmd.Dir
+	if dir == "" {
+		dir, _ = os.Getwd()
+	}
+	lines := []string{"dir: " + dir}
+	for _, kv := range envOverrides(cmd.Env) {
+		lines = append(lines, "env: "+kv)
+	}
+	return lines
+}
+
 func MustRunCommand(cmd string, args ...string) {
 	MustRun(exec.Command(cmd, args...))
 }
@@ -70,10 +96,16 @@ func VERSION() string {
 
 var warnedAboutGit bool
 
+// goBinary is the go tool used by the package listing and go tool helpers.
+var goBinary = filepath.Join(runtime.GOROOT(), "bin", "go")
+
+// gitBinary is the git executable used by RunGit and the other git helpers.
+var gitBinary = "/home/afranzin/software/bin/bin/git"
+
 // RunGit runs a git subcommand and returns its output.
 // The command must complete successfully.
 func RunGit(args ...string) string {
-	cmd := exec.Command("/home/afranzin/software/bin/bin/git", args...)
+	cmd := exec.Command(gitBinary, args...)
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout, cmd.Stderr = &stdout, &stderr
 	if err := cmd.Run(); err == exec.ErrNotFound {
@@ -96,46 +128,131 @@ func Render(templateFile, outputFile string, outputPerm os.FileMode, x interface
 
 // RenderString renders the given template string into outputFile.
 func RenderString(templateContent, outputFile string, outputPerm os.FileMode, x interface{}) {
-	tpl := template.Must(template.New("").Parse(templateContent))
+	tpl := template.Must(parseCached("", "", templateContent, nil))
 	render(tpl, outputFile, outputPerm, x)
 }
 
 func render(tpl *template.Template, outputFile string, outputPerm os.FileMode, x interface{}) {
-	if err := os.MkdirAll(filepath.Dir(outputFile), 0755); err != nil {
+	if err := renderFile(tpl, outputFile, outputPerm, x); err != nil {
 		log.Fatal(err)
 	}
+}
+
+// renderFile executes the template into a newly created outputFile, reporting
+// any failure to the caller. The output file must not exist yet.
+func renderFile(tpl *template.Template, outputFile string, outputPerm os.FileMode, x interface{}) error {
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, x); err != nil {
+		return err
+	}
+	return writeRendered(outputFile, outputPerm, buf.Bytes())
+}
+
+// renderPath resolves the path of a render output file within RenderRoot, if
+// one is set.
+func renderPath(outputFile string) (string, error) {
+	if RenderRoot == "" {
+		return outputFile, nil
+	}
+	return SafeJoin(RenderRoot, outputFile)
+}
+
+// writeRendered writes rendered template output into a newly created file. If
+// RenderRoot is set, outputFile is resolved within it.
+func writeRendered(outputFile string, outputPerm os.FileMode, data []byte) error {
+	outputFile, err := renderPath(outputFile)
+	if err != nil {
+		return err
+	}
+	return writeExclusive(outputFile, outputPerm, data)
+}
+
+// writeExclusive writes data into a newly created file, creating its parent
+// directories as needed. It fails if the file already exists.
+func writeExclusive(outputFile string, outputPerm os.FileMode, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(outputFile), 0755); err != nil {
+		return err
+	}
 	out, err := os.OpenFile(outputFile, os.O_CREATE|os.O_WRONLY|os.O_EXCL, outputPerm)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	if err := tpl.Execute(out, x); err != nil {
-		log.Fatal(err)
+	if _, err := out.Write(data); err != nil {
+		out.Close()
+		return err
 	}
-	if err := out.Close(); err != nil {
-		log.Fatal(err)
+	return out.Close()
+}
+
+// copyExclusive copies a file into a newly created one, creating its parent
+// directories as needed. It fails if dst already exists, and removes the
+// partially written file if the copy fails.
+func copyExclusive(dst, src string, mode os.FileMode) error {
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	destFile, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(destFile, srcFile); err == nil {
+		err = destFile.Close()
+	} else {
+		destFile.Close()
+	}
+	if err != nil {
+		os.Remove(dst)
 	}
+	return err
 }
 
 // CopyFile copies a file.
 func CopyFile(dst, src string, mode os.FileMode) {
-	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+	if err := copyFile(dst, src, mode); err != nil {
 		log.Fatal(err)
 	}
+}
+
+// copyFile copies a file, returning any failure to the caller.
+func copyFile(dst, src string, mode os.FileMode) error {
+	return copyFileBuffer(dst, src, mode, nil)
+}
+
+// copyFileBuffer copies a file through the given buffer. If buf is nil, the
+// most efficient copy method available is used.
+func copyFileBuffer(dst, src string, mode os.FileMode, buf []byte) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
 	destFile, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	defer destFile.Close()
 
 	srcFile, err := os.Open(src)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	defer srcFile.Close()
 
-	if _, err := io.Copy(destFile, srcFile); err != nil {
-		log.Fatal(err)
+	if buf == nil {
+		_, err = io.Copy(destFile, srcFile)
+	} else {
+		// Hide the files' ReadFrom and WriteTo methods, which would allocate their
+		// own buffers on fallback instead of using the given one.
+		_, err = io.CopyBuffer(struct{ io.Writer }{destFile}, struct{ io.Reader }{srcFile}, buf)
+	}
+	if err != nil {
+		return err
 	}
+	return destFile.Close()
 }
 
 // ExpandPackagesNoVendor expands a cmd/go import path pattern, skipping
@@ -149,7 +266,7 @@ func ExpandPackagesNoVendor(patterns []string) []string {
 	}
 	if expand {
 		args := append([]string{"list"}, patterns...)
-		cmd := exec.Command(filepath.Join(runtime.GOROOT(), "bin", "go"), args...)
+		cmd := exec.Command(goBinary, args...)
 		out, err := cmd.CombinedOutput()
 		if err != nil {
 			log.Fatalf("package listing failed: %v\n%s", err, string(out))
@@ -0,0 +1,145 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// versionPattern matches the semantic version format expected in VERSION.
+var versionPattern = regexp.MustCompile(`^[0-9]+\.[0-9]+\.[0-9]+(-[0-9A-Za-z.-]+)?$`)
+
+// MustVersion returns the content of the VERSION file like VERSION does, but
+// exits the host process if it isn't a single line semantic version.
+func MustVersion() string {
+	version, err := readVersionFile("VERSION")
+	if err != nil {
+		log.Fatal(err)
+	}
+	return version
+}
+
+// readVersionFile reads and validates a version file, tolerating only leading
+// and trailing whitespace around the version itself.
+func readVersionFile(path string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	version := string(bytes.TrimSpace(content))
+	if strings.ContainsAny(version, "\r\n") {
+		return "", fmt.Errorf("%s: version spans multiple lines", path)
+	}
+	if !versionPattern.MatchString(version) {
+		return "", fmt.Errorf("%s: invalid version %q, want MAJOR.MINOR.PATCH[-SUFFIX]", path, version)
+	}
+	return version, nil
+}
+
+// BumpVersion increments the "major", "minor" or "patch" component of a semantic
+// version, resetting the lower components to zero. Any pre-release suffix is
+// dropped, so bumping the patch of 1.7.0-unstable yields 1.7.1.
+func BumpVersion(current string, part string) (string, error) {
+	if !versionPattern.MatchString(current) {
+		return "", fmt.Errorf("invalid version %q, want MAJOR.MINOR.PATCH[-SUFFIX]", current)
+	}
+	core := strings.SplitN(current, "-", 2)[0]
+
+	var nums [3]int
+	for i, s := range strings.Split(core, ".") {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return "", fmt.Errorf("invalid version %q: %v", current, err)
+		}
+		nums[i] = n
+	}
+	switch part {
+	case "major":
+		nums = [3]int{nums[0] + 1, 0, 0}
+	case "minor":
+		nums = [3]int{nums[0], nums[1] + 1, 0}
+	case "patch":
+		nums[2]++
+	default:
+		return "", fmt.Errorf("unknown version part %q, want major, minor or patch", part)
+	}
+	return fmt.Sprintf("%d.%d.%d", nums[0], nums[1], nums[2]), nil
+}
+
+// versionFileTemplate is the source of the Go file emitted by WriteVersionFile.
+var versionFileTemplate = template.Must(template.New("").Parse(`// Code generated by internal/build. DO NOT EDIT.
+
+package {{.Package}}
+
+const (
+	Version   = {{printf "%q" .Version}} // Semantic version from the VERSION file
+	GitCommit = {{printf "%q" .Commit}} // Git commit the build was made from
+	GitDate   = {{printf "%q" .Date}} // Commit date of GitCommit (YYYYMMDD)
+	Dirty     = {{.Dirty}} // Whether the working tree had local changes
+)
+`))
+
+// WriteVersionFile generates a Go source file for the given package, declaring
+// the Version, GitCommit, GitDate and Dirty constants of the current checkout.
+// The version is read from the VERSION file of the working directory.
+func WriteVersionFile(pkg, outputFile string) error {
+	version, err := readVersionFile("VERSION")
+	if err != nil {
+		return err
+	}
+	commit, err := tryGit("rev-parse", "HEAD")
+	if err != nil {
+		return fmt.Errorf("can't determine git commit: %v", err)
+	}
+	stamp, err := tryGit("log", "-1", "--format=%ct", "HEAD")
+	if err != nil {
+		return fmt.Errorf("can't determine git commit date: %v", err)
+	}
+	unix, err := strconv.ParseInt(stamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid git commit timestamp %q: %v", stamp, err)
+	}
+	files, err := gitStatus()
+	if err != nil {
+		return fmt.Errorf("can't determine working tree state: %v", err)
+	}
+	var buf bytes.Buffer
+	err = versionFileTemplate.Execute(&buf, map[string]interface{}{
+		"Package": pkg,
+		"Version": version,
+		"Commit":  commit,
+		"Date":    time.Unix(unix, 0).UTC().Format("20060102"),
+		"Dirty":   len(files) > 0,
+	})
+	if err != nil {
+		return err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("generated invalid source: %v", err)
+	}
+	return ioutil.WriteFile(outputFile, src, 0644)
+}
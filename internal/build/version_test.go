@@ -0,0 +1,140 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Tests that version files are validated for semantic version format.
+func TestReadVersionFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "build-version-")
+	if err != nil {
+		t.Fatalf("failed to create temporary dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		content string
+		want    string
+		fail    bool
+	}{
+		{content: "1.6.1\n", want: "1.6.1"},
+		{content: "1.7.0-unstable", want: "1.7.0-unstable"},
+		{content: "1.6.1\n1.6.2\n", fail: true},
+		{content: "v1.6", fail: true},
+		{content: "latest\n", fail: true},
+	}
+	path := filepath.Join(dir, "VERSION")
+	for i, tt := range tests {
+		if err := ioutil.WriteFile(path, []byte(tt.content), 0644); err != nil {
+			t.Fatalf("test %d: failed to write version file: %v", i, err)
+		}
+		have, err := readVersionFile(path)
+		switch {
+		case tt.fail && err == nil:
+			t.Errorf("test %d: invalid version %q accepted", i, tt.content)
+		case !tt.fail && err != nil:
+			t.Errorf("test %d: valid version %q rejected: %v", i, tt.content, err)
+		case !tt.fail && have != tt.want:
+			t.Errorf("test %d: version mismatch: have %q, want %q", i, have, tt.want)
+		}
+	}
+}
+
+// Tests that version components are bumped with lower ones reset.
+func TestBumpVersion(t *testing.T) {
+	tests := []struct {
+		current string
+		part    string
+		want    string
+		fail    bool
+	}{
+		{current: "1.6.9", part: "major", want: "2.0.0"},
+		{current: "1.6.9", part: "minor", want: "1.7.0"},
+		{current: "1.6.9", part: "patch", want: "1.6.10"},
+		{current: "1.7.0-unstable", part: "patch", want: "1.7.1"},
+		{current: "1.6.9", part: "build", fail: true},
+		{current: "v1.6", part: "patch", fail: true},
+	}
+	for i, tt := range tests {
+		have, err := BumpVersion(tt.current, tt.part)
+		switch {
+		case tt.fail && err == nil:
+			t.Errorf("test %d: invalid bump of %q %s accepted", i, tt.current, tt.part)
+		case !tt.fail && err != nil:
+			t.Errorf("test %d: valid bump of %q %s rejected: %v", i, tt.current, tt.part, err)
+		case !tt.fail && have != tt.want:
+			t.Errorf("test %d: version mismatch: have %q, want %q", i, have, tt.want)
+		}
+	}
+}
+
+// Tests that the generated version file compiles and declares the version and
+// git details of the current checkout.
+func TestWriteVersionFile(t *testing.T) {
+	dir, cleanup := newTestRepo(t)
+	defer cleanup()
+
+	commitTestFile(t, "VERSION", "1.6.1\n")
+	commit, err := tryGit("rev-parse", "HEAD")
+	if err != nil {
+		t.Fatalf("failed to resolve commit: %v", err)
+	}
+	out := filepath.Join(dir, "version.go")
+	if err := WriteVersionFile("params", out); err != nil {
+		t.Fatalf("failed to write version file: %v", err)
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, out, nil, 0)
+	if err != nil {
+		t.Fatalf("failed to parse version file: %v", err)
+	}
+	if file.Name.Name != "params" {
+		t.Errorf("package mismatch: have %s, want params", file.Name.Name)
+	}
+	pkg, err := new(types.Config).Check("params", fset, []*ast.File{file}, nil)
+	if err != nil {
+		t.Fatalf("version file doesn't compile: %v", err)
+	}
+	want := map[string]string{
+		"Version":   `"1.6.1"`,
+		"GitCommit": `"` + commit + `"`,
+		"Dirty":     "false",
+	}
+	for name, value := range want {
+		obj, ok := pkg.Scope().Lookup(name).(*types.Const)
+		if !ok {
+			t.Errorf("constant %s not declared", name)
+			continue
+		}
+		if have := obj.Val().ExactString(); have != value {
+			t.Errorf("constant %s mismatch: have %s, want %s", name, have, value)
+		}
+	}
+	if date, ok := pkg.Scope().Lookup("GitDate").(*types.Const); !ok || len(strings.Trim(date.Val().ExactString(), `"`)) != 8 {
+		t.Errorf("invalid GitDate constant: %v", date)
+	}
+}